@@ -0,0 +1,57 @@
+package config
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"golang.org/x/net/html/charset"
+	"io"
+	"mime"
+)
+
+// newXMLDecoder creates a decoder that turns the document into UTF-8.
+// The charset from the Content-Type header wins over the encoding
+// in the XML declaration, as RFC 7303 says
+func newXMLDecoder(contentType string, data []byte) (*xml.Decoder, error) {
+	var r io.Reader = bytes.NewReader(data)
+
+	var headerCharset string
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		headerCharset = params["charset"]
+	}
+	if headerCharset != "" {
+		decoded, err := charset.NewReaderLabel(headerCharset, r)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported charset %q: %w", headerCharset, err)
+		}
+		r = decoded
+	}
+
+	d := xml.NewDecoder(r)
+	d.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		if headerCharset != "" {
+			// already decoded with the charset from the header
+			return input, nil
+		}
+		return newCharsetReader(label, input)
+	}
+	return d, nil
+}
+
+// newCharsetReader converts the input from the named encoding to UTF-8
+func newCharsetReader(label string, input io.Reader) (io.Reader, error) {
+	decoded, err := charset.NewReaderLabel(label, input)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported encoding %q: %w", label, err)
+	}
+	return decoded, nil
+}
+
+// decodeXML is xml.Unmarshal for documents that may not be in UTF-8
+func decodeXML(contentType string, data []byte, v any) error {
+	d, err := newXMLDecoder(contentType, data)
+	if err != nil {
+		return err
+	}
+	return d.Decode(v)
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultTimeout        = 30 * time.Second
+	defaultConnectTimeout = 10 * time.Second
+	defaultMaxBodyBytes   = 10 << 20
+	defaultMaxRedirects   = 5
+)
+
+var (
+	// ErrBodyTooLarge is returned when the feed is bigger than the configured maximum
+	ErrBodyTooLarge = errors.New("response body too large")
+	// ErrTooManyRedirects is returned when the feed redirects more times than allowed
+	ErrTooManyRedirects = errors.New("too many redirects")
+)
+
+// Client fetches feeds over HTTP, it is safe for concurrent use
+type Client struct {
+	http         *http.Client
+	maxBodyBytes int64
+}
+
+// NewClient creates the client used to fetch feeds,
+// a nil config or zero values fall back to the defaults
+func NewClient(cfg *HTTPConfig) *Client {
+	var c HTTPConfig
+	if cfg != nil {
+		c = *cfg
+	}
+	timeout := c.Timeout.OrDefault(defaultTimeout)
+	connectTimeout := c.ConnectTimeout.OrDefault(defaultConnectTimeout)
+	maxBodyBytes := c.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultMaxBodyBytes
+	}
+	maxRedirects := c.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = defaultMaxRedirects
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+	transport.ResponseHeaderTimeout = timeout
+
+	return &Client{
+		http: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > maxRedirects {
+					return fmt.Errorf("%w: stopped after %d", ErrTooManyRedirects, maxRedirects)
+				}
+				if t, ok := req.Context().Value(redirectTrackerKey{}).(*redirectTracker); ok {
+					t.follow(req)
+				}
+				return nil
+			},
+		},
+		maxBodyBytes: maxBodyBytes,
+	}
+}
+
+type redirectTrackerKey struct{}
+
+// redirectTracker remembers where a chain of permanent redirects
+// ended, the chain is broken by the first temporary redirect
+type redirectTracker struct {
+	temporary    bool
+	permanentURL string
+}
+
+func (t *redirectTracker) follow(req *http.Request) {
+	if t.temporary || req.Response == nil {
+		return
+	}
+	switch req.Response.StatusCode {
+	case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+		t.permanentURL = req.URL.String()
+	default:
+		t.temporary = true
+	}
+}
+
+// trackRedirects returns a context that makes the client record the redirects
+func trackRedirects(ctx context.Context) (context.Context, *redirectTracker) {
+	t := &redirectTracker{}
+	return context.WithValue(ctx, redirectTrackerKey{}, t), t
+}
+
+// readBody reads the whole body as long as it isn't bigger than the maximum
+func (c *Client) readBody(body io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(body, c.maxBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > c.maxBodyBytes {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrBodyTooLarge, c.maxBodyBytes)
+	}
+	return data, nil
+}
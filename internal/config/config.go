@@ -6,11 +6,50 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 type Config struct {
-	DBUrl           string `json:"db_url"`
-	CurrentUserName string `json:"current_user_name"`
+	DBUrl           string      `json:"db_url"`
+	CurrentUserName string      `json:"current_user_name"`
+	HTTP            *HTTPConfig `json:"http,omitempty"`
+}
+
+// HTTPConfig tunes the client used to fetch feeds, fields left out use the defaults
+type HTTPConfig struct {
+	// Timeout is the total time a fetch can take, reading the body included
+	Timeout        Duration `json:"timeout,omitempty"`
+	ConnectTimeout Duration `json:"connect_timeout,omitempty"`
+	MaxBodyBytes   int64    `json:"max_body_bytes,omitempty"`
+	MaxRedirects   int      `json:"max_redirects,omitempty"`
+}
+
+// Duration is a time.Duration written as a string like "30s" in the config file
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// OrDefault returns the duration or def when it isn't set
+func (d Duration) OrDefault(def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return time.Duration(d)
 }
 
 func Read() (Config, error) {
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"mime"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrHTMLPage is returned when the URL given as a feed is a web page
+var ErrHTMLPage = errors.New("url is a web page, not a feed")
+
+// HTMLPageError is returned by FetchFeed when the URL is a web page, it
+// keeps the page so its feeds can be discovered without downloading it again
+type HTMLPageError struct {
+	// URL is where the page was found after redirects
+	URL  string
+	Page []byte
+}
+
+func (e *HTMLPageError) Error() string {
+	return ErrHTMLPage.Error()
+}
+
+func (e *HTMLPageError) Is(target error) bool {
+	return target == ErrHTMLPage
+}
+
+// feedTypes are the types of <link rel="alternate"> that point to feeds
+var feedTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/rdf+xml":   true,
+	"application/feed+json": true,
+}
+
+// commonFeedPaths are tried when the page doesn't advertise its feeds
+var commonFeedPaths = []string{
+	"/feed",
+	"/rss",
+	"/rss.xml",
+	"/atom.xml",
+	"/feed.xml",
+	"/index.xml",
+	"/feed.json",
+}
+
+// commonFeedPathsTimeout bounds the time spent trying all of commonFeedPaths,
+// a site that doesn't have them shouldn't keep addfeed waiting for long
+const commonFeedPathsTimeout = 10 * time.Second
+
+// FeedCandidate is a feed found while looking at a web page
+type FeedCandidate struct {
+	URL   string
+	Title string
+	Type  string
+}
+
+func (c FeedCandidate) String() string {
+	if c.Title == "" {
+		return c.URL
+	}
+	return fmt.Sprintf("%s (%s)", c.Title, c.URL)
+}
+
+// DiscoverFeeds looks for the feeds a web page returned by FetchFeed advertises
+// with <link rel="alternate"> and, when there are none, tries the common feed paths
+func (c *Client) DiscoverFeeds(ctx context.Context, page *HTMLPageError) ([]FeedCandidate, error) {
+	// relative links are resolved against the URL we ended up at after redirects
+	base, err := url.Parse(page.URL)
+	if err != nil {
+		return nil, err
+	}
+	candidates := linkedFeeds(base, page.Page)
+	if len(candidates) > 0 {
+		return candidates, nil
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, commonFeedPathsTimeout)
+	defer cancel()
+	for _, path := range commonFeedPaths {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if probeCtx.Err() != nil {
+			break
+		}
+		candidate := base.ResolveReference(&url.URL{Path: path}).String()
+		feed, err := c.FetchFeed(probeCtx, candidate)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, FeedCandidate{URL: candidate, Title: feed.Channel.Title})
+	}
+	return candidates, nil
+}
+
+// linkedFeeds returns the feeds linked from the <head> of the page
+func linkedFeeds(base *url.URL, page []byte) []FeedCandidate {
+	var candidates []FeedCandidate
+	seen := map[string]bool{}
+
+	z := html.NewTokenizer(bytes.NewReader(page))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return candidates
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			switch t.DataAtom {
+			case atom.Body:
+				return candidates
+			case atom.Base:
+				if href := attr(t, "href"); href != "" {
+					if u, err := base.Parse(href); err == nil {
+						base = u
+					}
+				}
+			case atom.Link:
+				if !hasToken(attr(t, "rel"), "alternate") {
+					continue
+				}
+				feedType := strings.ToLower(strings.TrimSpace(attr(t, "type")))
+				if mediaType, _, err := mime.ParseMediaType(feedType); err == nil {
+					feedType = mediaType
+				}
+				if !feedTypes[feedType] {
+					continue
+				}
+				u, err := base.Parse(strings.TrimSpace(attr(t, "href")))
+				if err != nil || seen[u.String()] {
+					continue
+				}
+				seen[u.String()] = true
+				candidates = append(candidates, FeedCandidate{
+					URL:   u.String(),
+					Title: attr(t, "title"),
+					Type:  feedType,
+				})
+			}
+		}
+	}
+}
+
+func attr(t html.Token, name string) string {
+	for _, a := range t.Attr {
+		if strings.EqualFold(a.Key, name) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// hasToken tells if the space separated list contains the token
+func hasToken(list, token string) bool {
+	for _, t := range strings.Fields(list) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHTML tells if the document is a web page by sniffing the body, the
+// Content-Type isn't enough since some servers send feeds as text/html
+func isHTML(contentType string, data []byte) bool {
+	start := bytes.ToLower(bytes.TrimLeft(data, " \t\r\n\ufeff"))
+	if bytes.HasPrefix(start, []byte("<!doctype html")) || bytes.HasPrefix(start, []byte("<html")) {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != "text/html" && mediaType != "application/xhtml+xml") {
+		return false
+	}
+	for _, prefix := range []string{"<?xml", "<rss", "<feed", "<rdf:rdf", "{"} {
+		if bytes.HasPrefix(start, []byte(prefix)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiscoverFeedsReusesPage(t *testing.T) {
+	pageHits := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/blog/", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/blog/", func(w http.ResponseWriter, r *http.Request) {
+		pageHits++
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<!DOCTYPE html><html><head><link rel="alternate" type="application/rss+xml" title="Blog" href="feed.xml"></head><body></body></html>`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c := NewClient(nil)
+	_, err := c.FetchFeed(context.Background(), srv.URL+"/old")
+	var page *HTMLPageError
+	if !errors.As(err, &page) || !errors.Is(err, ErrHTMLPage) {
+		t.Fatalf("FetchFeed() error = %v, want an HTMLPageError", err)
+	}
+	if page.URL != srv.URL+"/blog/" {
+		t.Errorf("page.URL = %q, want %q", page.URL, srv.URL+"/blog/")
+	}
+
+	candidates, err := c.DiscoverFeeds(context.Background(), page)
+	if err != nil {
+		t.Fatalf("DiscoverFeeds() error = %v", err)
+	}
+	want := FeedCandidate{URL: srv.URL + "/blog/feed.xml", Title: "Blog", Type: "application/rss+xml"}
+	if len(candidates) != 1 || candidates[0] != want {
+		t.Errorf("DiscoverFeeds() = %v, want [%v]", candidates, want)
+	}
+	if pageHits != 1 {
+		t.Errorf("page fetched %d times, want 1", pageHits)
+	}
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// OPML is an OPML 2.0 document, the format feed readers use
+// to import and export subscriptions
+type OPML struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    struct {
+		Title       string `xml:"title"`
+		DateCreated string `xml:"dateCreated,omitempty"`
+	} `xml:"head"`
+	Body struct {
+		Outlines []OPMLOutline `xml:"outline"`
+	} `xml:"body"`
+}
+
+type OPMLOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Outlines []OPMLOutline `xml:"outline"`
+}
+
+// OPMLFeed is a feed found in an OPML document
+type OPMLFeed struct {
+	Title   string
+	XMLURL  string
+	HTMLURL string
+	// Folder is the path of the outlines the feed is nested in, separated by "/"
+	Folder string
+}
+
+func ParseOPML(r io.Reader) (*OPML, error) {
+	var doc OPML
+	d := xml.NewDecoder(r)
+	d.CharsetReader = newCharsetReader
+	if err := d.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// Feeds flattens the outlines into the feeds they contain,
+// outlines without xmlUrl are folders
+func (o *OPML) Feeds() []OPMLFeed {
+	var feeds []OPMLFeed
+	var walk func(outlines []OPMLOutline, folder []string)
+	walk = func(outlines []OPMLOutline, folder []string) {
+		for _, outline := range outlines {
+			title := strings.TrimSpace(outline.Title)
+			if title == "" {
+				title = strings.TrimSpace(outline.Text)
+			}
+			if outline.XMLURL == "" {
+				walk(outline.Outlines, append(folder, title))
+				continue
+			}
+			feeds = append(feeds, OPMLFeed{
+				Title:   title,
+				XMLURL:  strings.TrimSpace(outline.XMLURL),
+				HTMLURL: strings.TrimSpace(outline.HTMLURL),
+				Folder:  strings.Join(folder, "/"),
+			})
+		}
+	}
+	walk(o.Body.Outlines, nil)
+	return feeds
+}
+
+// Write writes the document with its XML declaration
+func (o *OPML) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(o); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOPMLFeeds(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want []OPMLFeed
+	}{
+		{
+			name: "flat",
+			doc: `<opml version="2.0"><body>
+<outline text="Go Blog" type="rss" xmlUrl=" https://go.dev/blog/feed.atom " htmlUrl="https://go.dev/blog"/>
+</body></opml>`,
+			want: []OPMLFeed{{Title: "Go Blog", XMLURL: "https://go.dev/blog/feed.atom", HTMLURL: "https://go.dev/blog"}},
+		},
+		{
+			name: "title wins over text",
+			doc: `<opml version="1.0"><body>
+<outline text="text" title="Title" xmlUrl="https://example.com/feed"/>
+</body></opml>`,
+			want: []OPMLFeed{{Title: "Title", XMLURL: "https://example.com/feed"}},
+		},
+		{
+			name: "nested folders",
+			doc: `<opml version="2.0"><body>
+<outline text="Tech">
+  <outline text="Go">
+    <outline text="Go Blog" xmlUrl="https://go.dev/blog/feed.atom"/>
+  </outline>
+  <outline text="Postgres" xmlUrl="https://postgresql.org/news.rss"/>
+</outline>
+<outline text="Top" xmlUrl="https://example.com/feed"/>
+</body></opml>`,
+			want: []OPMLFeed{
+				{Title: "Go Blog", XMLURL: "https://go.dev/blog/feed.atom", Folder: "Tech/Go"},
+				{Title: "Postgres", XMLURL: "https://postgresql.org/news.rss", Folder: "Tech"},
+				{Title: "Top", XMLURL: "https://example.com/feed"},
+			},
+		},
+		{
+			name: "iso-8859-1",
+			doc:  "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><opml version=\"2.0\"><body><outline text=\"Caf\xe9\" xmlUrl=\"https://example.com/feed\"/></body></opml>",
+			want: []OPMLFeed{{Title: "Café", XMLURL: "https://example.com/feed"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseOPML(strings.NewReader(tt.doc))
+			if err != nil {
+				t.Fatalf("ParseOPML() error = %v", err)
+			}
+			if got := doc.Feeds(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Feeds() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOPMLWriteRoundTrip(t *testing.T) {
+	doc := OPML{Version: "2.0"}
+	doc.Body.Outlines = []OPMLOutline{
+		{Text: "Top", XMLURL: "https://example.com/feed"},
+		{Text: "Tech", Outlines: []OPMLOutline{{Text: "Go Blog", XMLURL: "https://go.dev/blog/feed.atom"}}},
+	}
+
+	var buf bytes.Buffer
+	if err := doc.Write(&buf); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	parsed, err := ParseOPML(&buf)
+	if err != nil {
+		t.Fatalf("ParseOPML() error = %v", err)
+	}
+
+	want := []OPMLFeed{
+		{Title: "Top", XMLURL: "https://example.com/feed"},
+		{Title: "Go Blog", XMLURL: "https://go.dev/blog/feed.atom", Folder: "Tech"},
+	}
+	if got := parsed.Feeds(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Feeds() = %+v, want %+v", got, want)
+	}
+}
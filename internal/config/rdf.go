@@ -0,0 +1,34 @@
+package config
+
+import "encoding/xml"
+
+// RDFFeed is an RSS 1.0 document, its items are siblings of the channel
+// instead of children and dates come from the Dublin Core module
+type RDFFeed struct {
+	XMLName xml.Name `xml:"RDF"`
+	Channel struct {
+		Title           string `xml:"title"`
+		Link            string `xml:"link"`
+		Description     string `xml:"description"`
+		UpdatePeriod    string `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+		UpdateFrequency string `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+	} `xml:"channel"`
+	Item []RSSItem `xml:"item"`
+}
+
+// toRSS normalizes the RDF feed into the same model used for RSS feeds
+func (f *RDFFeed) toRSS() *RSSFeed {
+	var feed RSSFeed
+	feed.Channel.Title = f.Channel.Title
+	feed.Channel.Link = f.Channel.Link
+	feed.Channel.Description = f.Channel.Description
+	feed.Channel.UpdatePeriod = f.Channel.UpdatePeriod
+	feed.Channel.UpdateFrequency = f.Channel.UpdateFrequency
+	feed.Channel.Item = f.Item
+	for i, item := range feed.Channel.Item {
+		if item.GUID == "" {
+			feed.Channel.Item[i].GUID = item.About
+		}
+	}
+	return &feed
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RefreshHints is what a feed says about how often it changes
+type RefreshHints struct {
+	// Interval is the minimum time between two fetches, zero when the feed doesn't say
+	Interval time.Duration
+	// SkipHours are the hours of the day, in GMT, when the feed shouldn't be fetched
+	SkipHours []int
+	SkipDays  []time.Weekday
+}
+
+var updatePeriods = map[string]time.Duration{
+	"hourly":  time.Hour,
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+	"yearly":  365 * 24 * time.Hour,
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// RefreshHints reads <ttl>, <skipHours>, <skipDays> and the syndication
+// module, when both ttl and sy:updatePeriod are set the longest one wins
+func (f *RSSFeed) RefreshHints() RefreshHints {
+	var hints RefreshHints
+
+	if ttl, err := strconv.Atoi(strings.TrimSpace(f.Channel.TTL)); err == nil && ttl > 0 {
+		hints.Interval = time.Duration(ttl) * time.Minute
+	}
+
+	if period, ok := updatePeriods[strings.ToLower(strings.TrimSpace(f.Channel.UpdatePeriod))]; ok {
+		frequency, err := strconv.Atoi(strings.TrimSpace(f.Channel.UpdateFrequency))
+		if err != nil || frequency < 1 {
+			frequency = 1
+		}
+		hints.Interval = max(hints.Interval, period/time.Duration(frequency))
+	}
+
+	for _, h := range f.Channel.SkipHours {
+		// the spec allows 0-23 but some feeds use 24 for midnight
+		if hour, err := strconv.Atoi(strings.TrimSpace(h)); err == nil && hour >= 0 && hour <= 24 {
+			hints.SkipHours = append(hints.SkipHours, hour%24)
+		}
+	}
+
+	for _, d := range f.Channel.SkipDays {
+		if day, ok := weekdays[strings.ToLower(strings.TrimSpace(d))]; ok {
+			hints.SkipDays = append(hints.SkipDays, day)
+		}
+	}
+
+	return hints
+}
+
+// cacheLifetime is how long the response can be cached according to
+// Cache-Control max-age or, when there is none, to Expires
+func cacheLifetime(h http.Header, now time.Time) time.Duration {
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if !strings.EqualFold(name, "max-age") {
+			continue
+		}
+		if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		return 0
+	}
+
+	expires, err := http.ParseTime(h.Get("Expires"))
+	if err != nil {
+		return 0
+	}
+	if date, err := http.ParseTime(h.Get("Date")); err == nil {
+		now = date
+	}
+	return max(expires.Sub(now), 0)
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRefreshHints(t *testing.T) {
+	tests := []struct {
+		name            string
+		ttl             string
+		updatePeriod    string
+		updateFrequency string
+		skipHours       []string
+		skipDays        []string
+		want            RefreshHints
+	}{
+		{name: "nothing", want: RefreshHints{}},
+		{name: "ttl", ttl: "60", want: RefreshHints{Interval: time.Hour}},
+		{name: "invalid ttl", ttl: "soon", want: RefreshHints{}},
+		{name: "update period", updatePeriod: "daily", updateFrequency: "4", want: RefreshHints{Interval: 6 * time.Hour}},
+		{name: "update period without frequency", updatePeriod: "Hourly", want: RefreshHints{Interval: time.Hour}},
+		{name: "longest of ttl and update period", ttl: "120", updatePeriod: "hourly", want: RefreshHints{Interval: 2 * time.Hour}},
+		{
+			name:      "skip hours and days",
+			skipHours: []string{"0", " 23 ", "24", "25", "x"},
+			skipDays:  []string{"Saturday", "sunday", "Someday"},
+			want: RefreshHints{
+				SkipHours: []int{0, 23, 0},
+				SkipDays:  []time.Weekday{time.Saturday, time.Sunday},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var feed RSSFeed
+			feed.Channel.TTL = tt.ttl
+			feed.Channel.UpdatePeriod = tt.updatePeriod
+			feed.Channel.UpdateFrequency = tt.updateFrequency
+			feed.Channel.SkipHours = tt.skipHours
+			feed.Channel.SkipDays = tt.skipDays
+			if got := feed.RefreshHints(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RefreshHints() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheLifetime(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{name: "no headers", header: http.Header{}, want: 0},
+		{name: "max-age", header: http.Header{"Cache-Control": {"public, max-age=600"}}, want: 10 * time.Minute},
+		{name: "quoted max-age", header: http.Header{"Cache-Control": {`max-age="60"`}}, want: time.Minute},
+		{name: "max-age zero", header: http.Header{"Cache-Control": {"max-age=0"}}, want: 0},
+		{
+			name: "max-age wins over expires",
+			header: http.Header{
+				"Cache-Control": {"max-age=60"},
+				"Expires":       {"Tue, 02 Jan 2024 14:00:00 GMT"},
+			},
+			want: time.Minute,
+		},
+		{name: "expires", header: http.Header{"Expires": {"Tue, 02 Jan 2024 14:00:00 GMT"}}, want: 2 * time.Hour},
+		{
+			name: "expires relative to date",
+			header: http.Header{
+				"Expires": {"Tue, 02 Jan 2024 14:00:00 GMT"},
+				"Date":    {"Tue, 02 Jan 2024 13:30:00 GMT"},
+			},
+			want: 30 * time.Minute,
+		},
+		{name: "expires in the past", header: http.Header{"Expires": {"Mon, 01 Jan 2024 00:00:00 GMT"}}, want: 0},
+		{name: "invalid expires", header: http.Header{"Expires": {"0"}}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cacheLifetime(tt.header, now); got != tt.want {
+				t.Errorf("cacheLifetime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
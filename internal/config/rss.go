@@ -2,17 +2,27 @@ package config
 
 import (
 	"context"
+	"encoding/json"
 	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strings"
+	"time"
 )
 
 type RSSFeed struct {
 	Channel struct {
-		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
-		Description string    `xml:"description"`
-		Item        []RSSItem `xml:"item"`
+		Title           string    `xml:"title"`
+		Link            string    `xml:"link"`
+		Description     string    `xml:"description"`
+		TTL             string    `xml:"ttl"`
+		SkipHours       []string  `xml:"skipHours>hour"`
+		SkipDays        []string  `xml:"skipDays>day"`
+		UpdatePeriod    string    `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+		UpdateFrequency string    `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+		Item            []RSSItem `xml:"item"`
 	} `xml:"channel"`
 }
 
@@ -21,31 +31,169 @@ type RSSItem struct {
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
 	PubDate     string `xml:"pubDate"`
+	DCDate      string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	// GUID identifies the item even when its link changes, it's empty when the feed has none
+	GUID string `xml:"guid"`
+	// About is the rdf:about of RSS 1.0 items, their identifier
+	About string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
 }
 
-func FetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
-	var feed RSSFeed
+// FetchResult is the outcome of a conditional fetch of a feed
+type FetchResult struct {
+	// Feed is nil when the server answered 304 Not Modified
+	Feed         *RSSFeed
+	StatusCode   int
+	NotModified  bool
+	ETag         string
+	LastModified string
+	// CacheLifetime is how long the server said the response is fresh for
+	CacheLifetime time.Duration
+	// PermanentURL is where the feed moved to when the server
+	// answered with permanent redirects, empty otherwise
+	PermanentURL string
+}
+
+// StatusError is returned when the server answers with
+// a status that is neither a success nor a 304
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response status: %s", e.Status)
+}
+
+func (c *Client) FetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+	res, err := c.FetchFeedConditional(ctx, feedURL, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return res.Feed, nil
+}
+
+// FetchFeedConditional fetches the feed sending If-None-Match and
+// If-Modified-Since with the validators from the previous fetch, so
+// the server can skip sending the body when nothing changed
+func (c *Client) FetchFeedConditional(ctx context.Context, feedURL, etag, lastModified string) (*FetchResult, error) {
+	ctx, redirects := trackRedirects(ctx)
 	withContext, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
 	if err != nil {
 		return nil, err
 	}
 	withContext.Header.Set("User-Agent", "gator")
+	if etag != "" {
+		withContext.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		withContext.Header.Set("If-Modified-Since", lastModified)
+	}
 
-	client := http.Client{}
-	res, err := client.Do(withContext)
+	res, err := c.http.Do(withContext)
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 
-	data, err := io.ReadAll(res.Body)
+	result := &FetchResult{
+		StatusCode:    res.StatusCode,
+		ETag:          res.Header.Get("ETag"),
+		LastModified:  res.Header.Get("Last-Modified"),
+		CacheLifetime: cacheLifetime(res.Header, time.Now()),
+		PermanentURL:  redirects.permanentURL,
+	}
+	if res.StatusCode == http.StatusNotModified {
+		result.NotModified = true
+		return result, nil
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, &StatusError{StatusCode: res.StatusCode, Status: res.Status}
+	}
+
+	data, err := c.readBody(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Feed, err = decodeFeed(res.Header.Get("Content-Type"), data)
+	if errors.Is(err, ErrHTMLPage) {
+		return nil, &HTMLPageError{URL: res.Request.URL.String(), Page: data}
+	}
 	if err != nil {
 		return nil, err
 	}
+	return result, nil
+}
 
-	if err = xml.Unmarshal(data, &feed); err != nil {
+// decodeFeed looks at the content type and at the root element
+// of the document to decide which format the feed is in
+func decodeFeed(contentType string, data []byte) (*RSSFeed, error) {
+	if isHTML(contentType, data) {
+		return nil, ErrHTMLPage
+	}
+	if isJSONFeed(contentType, data) {
+		var feed JSONFeed
+		if err := json.Unmarshal(data, &feed); err != nil {
+			return nil, fmt.Errorf("error decoding json feed: %w", err)
+		}
+		return feed.toRSS(), nil
+	}
+
+	root, err := rootElement(contentType, data)
+	if err != nil {
 		return nil, err
 	}
 
-	return &feed, nil
+	switch root.Local {
+	case "rss":
+		var feed RSSFeed
+		if err := decodeXML(contentType, data, &feed); err != nil {
+			return nil, err
+		}
+		return feed.withDCDates(), nil
+	case "feed":
+		var feed AtomFeed
+		if err := decodeXML(contentType, data, &feed); err != nil {
+			return nil, err
+		}
+		return feed.toRSS(), nil
+	case "RDF":
+		var feed RDFFeed
+		if err := decodeXML(contentType, data, &feed); err != nil {
+			return nil, err
+		}
+		return feed.toRSS().withDCDates(), nil
+	default:
+		return nil, fmt.Errorf("unsupported feed format: <%s>", root.Local)
+	}
+}
+
+// withDCDates uses dc:date as the publication date
+// of the items that don't have a pubDate
+func (f *RSSFeed) withDCDates() *RSSFeed {
+	for i, item := range f.Channel.Item {
+		if item.PubDate == "" {
+			f.Channel.Item[i].PubDate = strings.TrimSpace(item.DCDate)
+		}
+	}
+	return f
+}
+
+func rootElement(contentType string, data []byte) (xml.Name, error) {
+	d, err := newXMLDecoder(contentType, data)
+	if err != nil {
+		return xml.Name{}, err
+	}
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return xml.Name{}, errors.New("empty feed document")
+		}
+		if err != nil {
+			return xml.Name{}, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
 }
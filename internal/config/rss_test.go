@@ -0,0 +1,147 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+// testItem holds the fields of an item every feed format is normalized into
+type testItem struct {
+	Title       string
+	Link        string
+	Description string
+	PubDate     string
+	GUID        string
+}
+
+func TestDecodeFeed(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		data        string
+		title       string
+		link        string
+		items       []testItem
+	}{
+		{
+			name:        "rss 2.0",
+			contentType: "application/rss+xml",
+			data: `<?xml version="1.0"?>
+<rss version="2.0"><channel>
+<title>Example</title><link>https://example.com/</link>
+<item><title>First</title><link>https://example.com/1</link><guid>urn:1</guid>
+<pubDate>Mon, 02 Jan 2006 15:04:05 +0000</pubDate></item>
+</channel></rss>`,
+			title: "Example",
+			link:  "https://example.com/",
+			items: []testItem{{Title: "First", Link: "https://example.com/1", PubDate: "Mon, 02 Jan 2006 15:04:05 +0000", GUID: "urn:1"}},
+		},
+		{
+			name:        "atom 1.0",
+			contentType: "application/atom+xml",
+			data: `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+<title>Atom Example</title>
+<link rel="self" href="https://example.com/feed.atom"/>
+<link href="https://example.com/"/>
+<entry><id> urn:entry:1 </id><title>Entry</title>
+<link rel="alternate" type="text/html" href="https://example.com/entry"/>
+<updated>2006-01-02T15:04:05Z</updated><summary>Short</summary></entry>
+</feed>`,
+			title: "Atom Example",
+			link:  "https://example.com/",
+			items: []testItem{{Title: "Entry", Link: "https://example.com/entry", Description: "Short", PubDate: "2006-01-02T15:04:05Z", GUID: "urn:entry:1"}},
+		},
+		{
+			name:        "rss 1.0",
+			contentType: "application/rdf+xml",
+			data: `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel rdf:about="https://example.com/"><title>RDF Example</title><link>https://example.com/</link></channel>
+<item rdf:about="https://example.com/rdf/1"><title>RDF Item</title><link>https://example.com/rdf/1</link>
+<dc:date>2006-01-02T15:04:05Z</dc:date></item>
+</rdf:RDF>`,
+			title: "RDF Example",
+			link:  "https://example.com/",
+			items: []testItem{{Title: "RDF Item", Link: "https://example.com/rdf/1", PubDate: "2006-01-02T15:04:05Z", GUID: "https://example.com/rdf/1"}},
+		},
+		{
+			name:        "json feed",
+			contentType: "application/feed+json",
+			data: `{"version": "https://jsonfeed.org/version/1.1", "title": "JSON Example", "home_page_url": "https://example.com/",
+"items": [{"id": "42", "url": "https://example.com/42", "title": "JSON Item", "content_text": "Text", "date_published": "2006-01-02T15:04:05Z"}]}`,
+			title: "JSON Example",
+			link:  "https://example.com/",
+			items: []testItem{{Title: "JSON Item", Link: "https://example.com/42", Description: "Text", PubDate: "2006-01-02T15:04:05Z", GUID: "42"}},
+		},
+		{
+			name:        "iso-8859-1 declaration",
+			contentType: "text/xml",
+			data:        "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<rss version=\"2.0\"><channel><title>Caf\xe9</title><item><title>D\xe9j\xe0 vu</title></item></channel></rss>",
+			title:       "Café",
+			items:       []testItem{{Title: "Déjà vu"}},
+		},
+		{
+			name:        "content type charset wins over the declaration",
+			contentType: "text/xml; charset=iso-8859-1",
+			data:        "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<rss version=\"2.0\"><channel><title>Caf\xe9</title></channel></rss>",
+			title:       "Café",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := decodeFeed(tt.contentType, []byte(tt.data))
+			if err != nil {
+				t.Fatalf("decodeFeed() error = %v", err)
+			}
+			if feed.Channel.Title != tt.title {
+				t.Errorf("title = %q, want %q", feed.Channel.Title, tt.title)
+			}
+			if feed.Channel.Link != tt.link {
+				t.Errorf("link = %q, want %q", feed.Channel.Link, tt.link)
+			}
+			if len(feed.Channel.Item) != len(tt.items) {
+				t.Fatalf("got %d items, want %d", len(feed.Channel.Item), len(tt.items))
+			}
+			for i, want := range tt.items {
+				item := feed.Channel.Item[i]
+				got := testItem{
+					Title:       item.Title,
+					Link:        item.Link,
+					Description: item.Description,
+					PubDate:     item.PubDate,
+					GUID:        item.GUID,
+				}
+				if got != want {
+					t.Errorf("item %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeFeedErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		data        string
+		wantErr     error
+	}{
+		{name: "html page", contentType: "text/html; charset=utf-8", data: "<!DOCTYPE html><html><head></head></html>", wantErr: ErrHTMLPage},
+		{name: "unknown root", contentType: "text/xml", data: "<?xml version=\"1.0\"?><note/>"},
+		{name: "empty document", contentType: "text/xml", data: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeFeed(tt.contentType, []byte(tt.data))
+			if err == nil {
+				t.Fatal("decodeFeed() error = nil, want an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("decodeFeed() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -11,12 +11,13 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createFeed = `-- name: CreateFeed :one
 insert into feeds (id, name, url, created_at, updated_at, user_id)
 values ($1, $2, $3, $4, $5, $6)
-returning id, name, url, user_id, created_at, updated_at, last_fetched_at
+returning id, name, url, user_id, created_at, updated_at, last_fetched_at, etag, last_modified, last_status_code, last_error, consecutive_failures, last_success_at, next_fetch_at, disabled_at, refresh_interval_seconds, skip_hours, skip_days, site_url
 `
 
 type CreateFeedParams struct {
@@ -46,17 +47,50 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.ConsecutiveFailures,
+		&i.LastSuccessAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.RefreshIntervalSeconds,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+		&i.SiteUrl,
 	)
 	return i, err
 }
 
-const getFeedByURL = `-- name: GetFeedByURL :one
-select id, name, url, user_id, created_at, updated_at, last_fetched_at from feeds
-where url = $1
+const deleteFeed = `-- name: DeleteFeed :exec
+delete from feeds
+where id = $1
 `
 
-func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getFeedByURL, url)
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
+const disableFeed = `-- name: DisableFeed :exec
+update feeds set updated_at = now(), disabled_at = now()
+where id = $1
+`
+
+func (q *Queries) DisableFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, disableFeed, id)
+	return err
+}
+
+const enableFeed = `-- name: EnableFeed :one
+update feeds set updated_at = now(), disabled_at = null, consecutive_failures = 0, next_fetch_at = null
+where id = $1
+returning id, name, url, user_id, created_at, updated_at, last_fetched_at, etag, last_modified, last_status_code, last_error, consecutive_failures, last_success_at, next_fetch_at, disabled_at, refresh_interval_seconds, skip_hours, skip_days, site_url
+`
+
+func (q *Queries) EnableFeed(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, enableFeed, id)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -66,18 +100,33 @@ func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.ConsecutiveFailures,
+		&i.LastSuccessAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.RefreshIntervalSeconds,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+		&i.SiteUrl,
 	)
 	return i, err
 }
 
-const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-select id, name, url, user_id, created_at, updated_at, last_fetched_at from feeds
-order by feeds.last_fetched_at NULLS FIRST
+const getFeedByURL = `-- name: GetFeedByURL :one
+select id, name, url, user_id, created_at, updated_at, last_fetched_at, etag, last_modified, last_status_code, last_error, consecutive_failures, last_success_at, next_fetch_at, disabled_at, refresh_interval_seconds, skip_hours, skip_days, site_url from feeds
+where url = $1
+or id = (select feed_id from feed_url_aliases where feed_url_aliases.url = $1)
+order by url = $1 desc
 limit 1
 `
 
-func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
+// resolves the old URLs of feeds that were permanently redirected
+func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByURL, url)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -87,29 +136,168 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.ConsecutiveFailures,
+		&i.LastSuccessAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.RefreshIntervalSeconds,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+		&i.SiteUrl,
 	)
 	return i, err
 }
 
+const getFeedsHealthForUser = `-- name: GetFeedsHealthForUser :many
+select feeds.id, feeds.name, feeds.url, feeds.user_id, feeds.created_at, feeds.updated_at, feeds.last_fetched_at, feeds.etag, feeds.last_modified, feeds.last_status_code, feeds.last_error, feeds.consecutive_failures, feeds.last_success_at, feeds.next_fetch_at, feeds.disabled_at, feeds.refresh_interval_seconds, feeds.skip_hours, feeds.skip_days, feeds.site_url from feeds
+join feed_follows on feed_follows.feed_id = feeds.id
+where feed_follows.user_id = $1
+order by feeds.consecutive_failures desc, feeds.name
+`
+
+func (q *Queries) GetFeedsHealthForUser(ctx context.Context, userID uuid.UUID) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsHealthForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.ConsecutiveFailures,
+			&i.LastSuccessAt,
+			&i.NextFetchAt,
+			&i.DisabledAt,
+			&i.RefreshIntervalSeconds,
+			pq.Array(&i.SkipHours),
+			pq.Array(&i.SkipDays),
+			&i.SiteUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getNextFeedsToFetch = `-- name: GetNextFeedsToFetch :many
+update feeds set next_fetch_at = now() + make_interval(secs => $1::integer)
+where id in (
+    select id from feeds
+    where disabled_at is null
+    and (next_fetch_at is null or next_fetch_at <= now())
+    order by feeds.last_fetched_at NULLS FIRST
+    limit $2
+    for update skip locked
+)
+returning id, name, url, user_id, created_at, updated_at, last_fetched_at, etag, last_modified, last_status_code, last_error, consecutive_failures, last_success_at, next_fetch_at, disabled_at, refresh_interval_seconds, skip_hours, skip_days, site_url
+`
+
+type GetNextFeedsToFetchParams struct {
+	LeaseSeconds int32
+	BatchSize    int32
+}
+
+// claims the due feeds by leasing them, next_fetch_at is pushed lease_seconds
+// forward so other aggregators don't pick them up while they are being fetched,
+// fetching the feed then replaces the lease with its real next fetch.
+// last_fetched_at is left to the fetch so a feed that never got stored stays first in line
+func (q *Queries) GetNextFeedsToFetch(ctx context.Context, arg GetNextFeedsToFetchParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getNextFeedsToFetch, arg.LeaseSeconds, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.ConsecutiveFailures,
+			&i.LastSuccessAt,
+			&i.NextFetchAt,
+			&i.DisabledAt,
+			&i.RefreshIntervalSeconds,
+			pq.Array(&i.SkipHours),
+			pq.Array(&i.SkipDays),
+			&i.SiteUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listFeeds = `-- name: ListFeeds :many
-select feeds.id, feeds.name, url, user_id, feeds.created_at, feeds.updated_at, last_fetched_at, users.id, users.name, users.created_at, users.updated_at from feeds
+select feeds.id, feeds.name, url, user_id, feeds.created_at, feeds.updated_at, last_fetched_at, etag, last_modified, last_status_code, last_error, consecutive_failures, last_success_at, next_fetch_at, disabled_at, refresh_interval_seconds, skip_hours, skip_days, site_url, users.id, users.name, users.created_at, users.updated_at from feeds
 join users
 on users.id = feeds.user_id
 where user_id = $1
 `
 
 type ListFeedsRow struct {
-	ID            uuid.UUID
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	LastFetchedAt sql.NullTime
-	ID_2          uuid.UUID
-	Name_2        string
-	CreatedAt_2   time.Time
-	UpdatedAt_2   time.Time
+	ID                     uuid.UUID
+	Name                   string
+	Url                    string
+	UserID                 uuid.UUID
+	CreatedAt              time.Time
+	UpdatedAt              time.Time
+	LastFetchedAt          sql.NullTime
+	Etag                   sql.NullString
+	LastModified           sql.NullString
+	LastStatusCode         sql.NullInt32
+	LastError              sql.NullString
+	ConsecutiveFailures    int32
+	LastSuccessAt          sql.NullTime
+	NextFetchAt            sql.NullTime
+	DisabledAt             sql.NullTime
+	RefreshIntervalSeconds sql.NullInt32
+	SkipHours              []int32
+	SkipDays               []int32
+	SiteUrl                sql.NullString
+	ID_2                   uuid.UUID
+	Name_2                 string
+	CreatedAt_2            time.Time
+	UpdatedAt_2            time.Time
 }
 
 func (q *Queries) ListFeeds(ctx context.Context, userID uuid.UUID) ([]ListFeedsRow, error) {
@@ -129,6 +317,18 @@ func (q *Queries) ListFeeds(ctx context.Context, userID uuid.UUID) ([]ListFeedsR
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.ConsecutiveFailures,
+			&i.LastSuccessAt,
+			&i.NextFetchAt,
+			&i.DisabledAt,
+			&i.RefreshIntervalSeconds,
+			pq.Array(&i.SkipHours),
+			pq.Array(&i.SkipDays),
+			&i.SiteUrl,
 			&i.ID_2,
 			&i.Name_2,
 			&i.CreatedAt_2,
@@ -147,14 +347,106 @@ func (q *Queries) ListFeeds(ctx context.Context, userID uuid.UUID) ([]ListFeedsR
 	return items, nil
 }
 
+const markFeedFailed = `-- name: MarkFeedFailed :one
+update feeds set updated_at = now(), last_fetched_at = now(),
+    last_status_code = $1, last_error = $2, consecutive_failures = consecutive_failures + 1,
+    next_fetch_at = now() + make_interval(secs => $3::integer)
+where id = $4
+returning id, name, url, user_id, created_at, updated_at, last_fetched_at, etag, last_modified, last_status_code, last_error, consecutive_failures, last_success_at, next_fetch_at, disabled_at, refresh_interval_seconds, skip_hours, skip_days, site_url
+`
+
+type MarkFeedFailedParams struct {
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	RetrySeconds   int32
+	ID             uuid.UUID
+}
+
+// the retry is counted from the database clock like the lease in GetNextFeedsToFetch
+func (q *Queries) MarkFeedFailed(ctx context.Context, arg MarkFeedFailedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, markFeedFailed,
+		arg.LastStatusCode,
+		arg.LastError,
+		arg.RetrySeconds,
+		arg.ID,
+	)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.ConsecutiveFailures,
+		&i.LastSuccessAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.RefreshIntervalSeconds,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+		&i.SiteUrl,
+	)
+	return i, err
+}
+
 const markFeedFetched = `-- name: MarkFeedFetched :one
-update feeds set updated_at = now(), last_fetched_at = now()
-where id = $1
-returning id, name, url, user_id, created_at, updated_at, last_fetched_at
+update feeds set updated_at = now(), last_fetched_at = now(), etag = $1, last_modified = $2,
+    last_status_code = $3, last_error = null, consecutive_failures = 0, last_success_at = now(),
+    refresh_interval_seconds = $4, skip_hours = $5, skip_days = $6,
+    next_fetch_at = case
+        when $7::integer = 0 and cardinality($5::integer[]) = 0 and cardinality($6::integer[]) = 0 then null
+        else (
+            select candidate from (
+                select 0 as step, now() + make_interval(secs => $7::integer) as candidate
+                union all
+                select step, (date_trunc('hour', (now() + make_interval(secs => $7::integer)) at time zone 'UTC') at time zone 'UTC') + make_interval(hours => step)
+                from generate_series(1, 7 * 24) as step
+            ) as candidates
+            where (extract(hour from candidate at time zone 'UTC')::integer <> all($5::integer[])
+                and extract(dow from candidate at time zone 'UTC')::integer <> all($6::integer[]))
+            or step = 7 * 24
+            order by step
+            limit 1
+        )
+    end,
+    site_url = coalesce($8, site_url)
+where id = $9
+returning id, name, url, user_id, created_at, updated_at, last_fetched_at, etag, last_modified, last_status_code, last_error, consecutive_failures, last_success_at, next_fetch_at, disabled_at, refresh_interval_seconds, skip_hours, skip_days, site_url
 `
 
-func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, markFeedFetched, id)
+type MarkFeedFetchedParams struct {
+	Etag                   sql.NullString
+	LastModified           sql.NullString
+	LastStatusCode         sql.NullInt32
+	RefreshIntervalSeconds sql.NullInt32
+	SkipHours              []int32
+	SkipDays               []int32
+	NextFetchSeconds       int32
+	SiteUrl                sql.NullString
+	ID                     uuid.UUID
+}
+
+// next_fetch_at is next_fetch_seconds from now on the database clock, moved forward
+// an hour at a time until it is out of skip_hours and skip_days, which are in GMT,
+// but never more than a week in case the feed asks to skip every hour of every day
+func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, markFeedFetched,
+		arg.Etag,
+		arg.LastModified,
+		arg.LastStatusCode,
+		arg.RefreshIntervalSeconds,
+		pq.Array(arg.SkipHours),
+		pq.Array(arg.SkipDays),
+		arg.NextFetchSeconds,
+		arg.SiteUrl,
+		arg.ID,
+	)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -164,6 +456,48 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) (Feed, erro
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.ConsecutiveFailures,
+		&i.LastSuccessAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.RefreshIntervalSeconds,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+		&i.SiteUrl,
 	)
 	return i, err
 }
+
+const setFeedSiteURL = `-- name: SetFeedSiteURL :exec
+update feeds set site_url = $2, updated_at = now()
+where id = $1
+`
+
+type SetFeedSiteURLParams struct {
+	ID      uuid.UUID
+	SiteUrl sql.NullString
+}
+
+func (q *Queries) SetFeedSiteURL(ctx context.Context, arg SetFeedSiteURLParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedSiteURL, arg.ID, arg.SiteUrl)
+	return err
+}
+
+const updateFeedURL = `-- name: UpdateFeedURL :exec
+update feeds set url = $2, updated_at = now()
+where id = $1
+`
+
+type UpdateFeedURLParams struct {
+	ID  uuid.UUID
+	Url string
+}
+
+func (q *Queries) UpdateFeedURL(ctx context.Context, arg UpdateFeedURLParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedURL, arg.ID, arg.Url)
+	return err
+}
@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,8 +17,8 @@ const createFeedFollow = `-- name: CreateFeedFollow :many
 WITH inserted_feed_follow AS (
     insert into feed_follows(id, user_id, feed_id, created_at, updated_at)
         values ($1, $2, $3, $4, $5)
-    returning id, user_id, feed_id, created_at, updated_at)
-SELECT inserted_feed_follow.id, inserted_feed_follow.user_id, inserted_feed_follow.feed_id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at,
+    returning id, user_id, feed_id, created_at, updated_at, category_id)
+SELECT inserted_feed_follow.id, inserted_feed_follow.user_id, inserted_feed_follow.feed_id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.category_id,
     feeds.name AS feed_name,
     users.name AS user_name
 FROM inserted_feed_follow
@@ -34,13 +35,14 @@ type CreateFeedFollowParams struct {
 }
 
 type CreateFeedFollowRow struct {
-	ID        uuid.UUID
-	UserID    uuid.UUID
-	FeedID    uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	FeedName  string
-	UserName  string
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	FeedID     uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	CategoryID uuid.NullUUID
+	FeedName   string
+	UserName   string
 }
 
 func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowParams) ([]CreateFeedFollowRow, error) {
@@ -64,6 +66,7 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 			&i.FeedID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.CategoryID,
 			&i.FeedName,
 			&i.UserName,
 		); err != nil {
@@ -83,7 +86,12 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 const deleteFeedFollow = `-- name: DeleteFeedFollow :exec
 delete from feed_follows
 where feed_follows.user_id = $1
-and (select id from feeds where url = $2) = feed_id
+and feed_id = (
+    select id from feeds where url = $2
+    union
+    select feed_url_aliases.feed_id from feed_url_aliases where feed_url_aliases.url = $2
+    limit 1
+)
 `
 
 type DeleteFeedFollowParams struct {
@@ -100,24 +108,44 @@ const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
 SELECT feed_follows.id,
        feeds.id as feedId,
        feeds.name as feedName,
+       feeds.url as feedUrl,
+       feeds.site_url as siteUrl,
        users.id as userId,
-       users.name as userName
+       users.name as userName,
+       categories.name as categoryName,
+       (select count(*) from posts
+        left join post_reads on post_reads.post_id = posts.id and post_reads.user_id = feed_follows.user_id
+        where posts.feed_id = feeds.id
+        and (post_reads.post_id is null or post_reads.content_hash <> posts.content_hash)) as unreadCount
 FROM public.feed_follows
 INNER JOIN users ON users.id = feed_follows.user_id
 INNER JOIN feeds ON feeds.id = feed_follows.feed_id
+LEFT JOIN categories ON categories.id = feed_follows.category_id
 WHERE feed_follows.user_id = $1
+AND ($2::uuid IS NULL OR feed_follows.category_id = $2::uuid)
+ORDER BY categories.name NULLS FIRST, feeds.name
 `
 
+type GetFeedFollowsForUserParams struct {
+	UserID     uuid.UUID
+	CategoryID uuid.NullUUID
+}
+
 type GetFeedFollowsForUserRow struct {
-	ID       uuid.UUID
-	Feedid   uuid.UUID
-	Feedname string
-	Userid   uuid.UUID
-	Username string
+	ID           uuid.UUID
+	Feedid       uuid.UUID
+	Feedname     string
+	Feedurl      string
+	Siteurl      sql.NullString
+	Userid       uuid.UUID
+	Username     string
+	Categoryname sql.NullString
+	Unreadcount  int64
 }
 
-func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForUserRow, error) {
-	rows, err := q.db.QueryContext(ctx, getFeedFollowsForUser, userID)
+// feeds come grouped by category, the uncategorized ones first
+func (q *Queries) GetFeedFollowsForUser(ctx context.Context, arg GetFeedFollowsForUserParams) ([]GetFeedFollowsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedFollowsForUser, arg.UserID, arg.CategoryID)
 	if err != nil {
 		return nil, err
 	}
@@ -129,8 +157,12 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 			&i.ID,
 			&i.Feedid,
 			&i.Feedname,
+			&i.Feedurl,
+			&i.Siteurl,
 			&i.Userid,
 			&i.Username,
+			&i.Categoryname,
+			&i.Unreadcount,
 		); err != nil {
 			return nil, err
 		}
@@ -144,3 +176,41 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 	}
 	return items, nil
 }
+
+const moveFeedFollows = `-- name: MoveFeedFollows :exec
+insert into feed_follows (id, user_id, feed_id, created_at, updated_at, category_id)
+select gen_random_uuid(), user_id, $1::uuid, created_at, now(), category_id
+from feed_follows
+where feed_follows.feed_id = $2
+on conflict (feed_id, user_id) do nothing
+`
+
+type MoveFeedFollowsParams struct {
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MoveFeedFollows(ctx context.Context, arg MoveFeedFollowsParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedFollows, arg.ToFeedID, arg.FromFeedID)
+	return err
+}
+
+const setFeedFollowCategory = `-- name: SetFeedFollowCategory :execrows
+update feed_follows set category_id = $1, updated_at = now()
+where user_id = $2 and feed_id = $3
+`
+
+type SetFeedFollowCategoryParams struct {
+	CategoryID uuid.NullUUID
+	UserID     uuid.UUID
+	FeedID     uuid.UUID
+}
+
+// a null category leaves the feed uncategorized
+func (q *Queries) SetFeedFollowCategory(ctx context.Context, arg SetFeedFollowCategoryParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setFeedFollowCategory, arg.CategoryID, arg.UserID, arg.FeedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -11,33 +11,76 @@ import (
 	"github.com/google/uuid"
 )
 
+type Category struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	LastFetchedAt sql.NullTime
+	ID                     uuid.UUID
+	Name                   string
+	Url                    string
+	UserID                 uuid.UUID
+	CreatedAt              time.Time
+	UpdatedAt              time.Time
+	LastFetchedAt          sql.NullTime
+	Etag                   sql.NullString
+	LastModified           sql.NullString
+	LastStatusCode         sql.NullInt32
+	LastError              sql.NullString
+	ConsecutiveFailures    int32
+	LastSuccessAt          sql.NullTime
+	NextFetchAt            sql.NullTime
+	DisabledAt             sql.NullTime
+	RefreshIntervalSeconds sql.NullInt32
+	SkipHours              []int32
+	SkipDays               []int32
+	SiteUrl                sql.NullString
 }
 
 type FeedFollow struct {
-	ID        uuid.UUID
-	UserID    uuid.UUID
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	FeedID     uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	CategoryID uuid.NullUUID
+}
+
+type FeedUrlAlias struct {
+	Url       string
 	FeedID    uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
 }
 
 type Post struct {
-	ID          uuid.UUID
-	Title       string
-	Description string
-	Url         string
-	PublishedAt time.Time
-	FeedID      uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID           uuid.UUID
+	Title        string
+	Description  string
+	Url          string
+	PublishedAt  time.Time
+	FeedID       uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Guid         string
+	ContentHash  string
+	SearchVector interface{}
+}
+
+type PostRead struct {
+	UserID      uuid.UUID
+	PostID      uuid.UUID
+	ContentHash string
+	ReadAt      time.Time
+}
+
+type PostStar struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	CreatedAt time.Time
 }
 
 type User struct {
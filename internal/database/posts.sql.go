@@ -7,99 +7,275 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-const createPost = `-- name: CreatePost :one
-INSERT INTO posts(id, feed_id, title, url, description, published_at, created_at, updated_at)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, title, description, url, published_at, feed_id, created_at, updated_at
+const adoptLegacyPosts = `-- name: AdoptLegacyPosts :exec
+update posts set guid = batch.guid
+from unnest($1::text[], $2::text[]) as batch(url, guid)
+where posts.feed_id = $3::uuid
+and posts.url = batch.url
+and posts.guid = posts.url
+and posts.guid <> batch.guid
+and not exists (
+    select 1 from posts existing
+    where existing.feed_id = posts.feed_id and existing.guid = batch.guid
+)
+`
+
+type AdoptLegacyPostsParams struct {
+	Urls   []string
+	Guids  []string
+	FeedID uuid.UUID
+}
+
+// posts stored before guids existed got their url as guid, when one of them
+// is in the batch under its real guid it takes that guid instead of being duplicated
+func (q *Queries) AdoptLegacyPosts(ctx context.Context, arg AdoptLegacyPostsParams) error {
+	_, err := q.db.ExecContext(ctx, adoptLegacyPosts, pq.Array(arg.Urls), pq.Array(arg.Guids), arg.FeedID)
+	return err
+}
+
+const getPostsForUser = `-- name: GetPostsForUser :many
+SELECT posts.id,
+       posts.title,
+       posts.url,
+       posts.description,
+       posts.published_at,
+       feeds.id AS feed_id,
+       feeds.name AS feed_name,
+       (post_reads.post_id IS NOT NULL) AS read,
+       coalesce(post_reads.content_hash <> posts.content_hash, false) AS edited
+FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+INNER JOIN feeds ON feeds.id = posts.feed_id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+AND ($2::boolean OR post_reads.post_id IS NULL OR post_reads.content_hash <> posts.content_hash)
+AND ($3::uuid IS NULL OR feed_follows.category_id = $3::uuid)
+AND ($4::timestamp IS NULL
+     OR (posts.published_at, posts.id) < ($4::timestamp, $5::uuid))
+AND ($6::timestamp IS NULL
+     OR (posts.published_at, posts.id) > ($6::timestamp, $7::uuid))
+ORDER BY
+    CASE WHEN $6::timestamp IS NULL THEN posts.published_at END DESC,
+    CASE WHEN $6::timestamp IS NULL THEN posts.id END DESC,
+    posts.published_at ASC,
+    posts.id ASC
+LIMIT $8
 `
 
-type CreatePostParams struct {
+type GetPostsForUserParams struct {
+	UserID            uuid.UUID
+	IncludeRead       bool
+	CategoryID        uuid.NullUUID
+	BeforePublishedAt sql.NullTime
+	BeforeID          uuid.NullUUID
+	AfterPublishedAt  sql.NullTime
+	AfterID           uuid.NullUUID
+	RowLimit          int32
+}
+
+type GetPostsForUserRow struct {
 	ID          uuid.UUID
-	FeedID      uuid.UUID
 	Title       string
 	Url         string
 	Description string
 	PublishedAt time.Time
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	FeedID      uuid.UUID
+	FeedName    string
+	Read        bool
+	Edited      bool
 }
 
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, createPost,
-		arg.ID,
-		arg.FeedID,
-		arg.Title,
-		arg.Url,
-		arg.Description,
-		arg.PublishedAt,
-		arg.CreatedAt,
-		arg.UpdatedAt,
+// pages through the posts of the feeds the user follows with a (published_at, id) cursor,
+// posts come newest first, or oldest first when paging forward with the after cursor.
+// posts edited since the user read them count as unread
+func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.UserID,
+		arg.IncludeRead,
+		arg.CategoryID,
+		arg.BeforePublishedAt,
+		arg.BeforeID,
+		arg.AfterPublishedAt,
+		arg.AfterID,
+		arg.RowLimit,
 	)
-	var i Post
-	err := row.Scan(
-		&i.ID,
-		&i.Title,
-		&i.Description,
-		&i.Url,
-		&i.PublishedAt,
-		&i.FeedID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostsForUserRow
+	for rows.Next() {
+		var i GetPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.FeedName,
+			&i.Read,
+			&i.Edited,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const movePosts = `-- name: MovePosts :exec
+update posts set feed_id = $1, updated_at = now()
+where posts.feed_id = $2
+and not exists (
+    select 1 from posts existing
+    where existing.feed_id = $1 and existing.guid = posts.guid
+)
+`
+
+type MovePostsParams struct {
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+// posts already in the destination feed stay behind and go away with the old feed
+func (q *Queries) MovePosts(ctx context.Context, arg MovePostsParams) error {
+	_, err := q.db.ExecContext(ctx, movePosts, arg.ToFeedID, arg.FromFeedID)
+	return err
+}
+
+const upsertPosts = `-- name: UpsertPosts :many
+INSERT INTO posts(feed_id, title, url, description, published_at, guid, content_hash)
+SELECT $1::uuid,
+       unnest($2::text[]),
+       unnest($3::text[]),
+       unnest($4::text[]),
+       unnest($5::timestamp[]),
+       unnest($6::text[]),
+       unnest($7::text[])
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET title = excluded.title,
+    url = excluded.url,
+    description = excluded.description,
+    published_at = excluded.published_at,
+    content_hash = excluded.content_hash,
+    updated_at = now()
+WHERE posts.content_hash <> excluded.content_hash
+RETURNING guid, (xmax = 0) AS inserted
+`
+
+type UpsertPostsParams struct {
+	FeedID        uuid.UUID
+	Titles        []string
+	Urls          []string
+	Descriptions  []string
+	PublishedAts  []time.Time
+	Guids         []string
+	ContentHashes []string
+}
+
+type UpsertPostsRow struct {
+	Guid     string
+	Inserted bool
+}
+
+// stores all the items of a feed in one statement, the batch must not repeat a guid.
+// posts already stored are only rewritten when their content changed
+// and only the inserted or rewritten posts are returned
+func (q *Queries) UpsertPosts(ctx context.Context, arg UpsertPostsParams) ([]UpsertPostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, upsertPosts,
+		arg.FeedID,
+		pq.Array(arg.Titles),
+		pq.Array(arg.Urls),
+		pq.Array(arg.Descriptions),
+		pq.Array(arg.PublishedAts),
+		pq.Array(arg.Guids),
+		pq.Array(arg.ContentHashes),
 	)
-	return i, err
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UpsertPostsRow
+	for rows.Next() {
+		var i UpsertPostsRow
+		if err := rows.Scan(&i.Guid, &i.Inserted); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const getPostsForFeedOfUser = `-- name: GetPostsForFeedOfUser :many
+const searchPostsForUser = `-- name: SearchPostsForUser :many
 SELECT posts.id,
        posts.title,
        posts.url,
-       posts.description,
        posts.published_at,
-       feeds.id as feedId,
-       feeds.name as feedName
-FROM public.posts
+       feeds.name AS feed_name,
+       ts_headline('english', posts.title || ' ' || posts.description, websearch_to_tsquery('english', $1::text),
+                   'StartSel=**, StopSel=**, MaxWords=25, MinWords=10, MaxFragments=2') AS snippet
+FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
 INNER JOIN feeds ON feeds.id = posts.feed_id
-WHERE (select feed_follows.feed_id from feed_follows where feed_follows.feed_id = posts.feed_id) = $1
-AND (select feed_follows.user_id from feed_follows where feed_follows.user_id = $2) = $2
+WHERE feed_follows.user_id = $2
+AND posts.search_vector @@ websearch_to_tsquery('english', $1::text)
+ORDER BY ts_rank(posts.search_vector, websearch_to_tsquery('english', $1::text)) DESC,
+         posts.published_at DESC
+LIMIT $3
 `
 
-type GetPostsForFeedOfUserParams struct {
-	FeedID uuid.UUID
-	UserID uuid.UUID
+type SearchPostsForUserParams struct {
+	Query    string
+	UserID   uuid.UUID
+	RowLimit int32
 }
 
-type GetPostsForFeedOfUserRow struct {
+type SearchPostsForUserRow struct {
 	ID          uuid.UUID
 	Title       string
 	Url         string
-	Description string
 	PublishedAt time.Time
-	Feedid      uuid.UUID
-	Feedname    string
+	FeedName    string
+	Snippet     string
 }
 
-func (q *Queries) GetPostsForFeedOfUser(ctx context.Context, arg GetPostsForFeedOfUserParams) ([]GetPostsForFeedOfUserRow, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForFeedOfUser, arg.FeedID, arg.UserID)
+// full text search over the posts of the feeds the user follows, best matches first
+func (q *Queries) SearchPostsForUser(ctx context.Context, arg SearchPostsForUserParams) ([]SearchPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchPostsForUser, arg.Query, arg.UserID, arg.RowLimit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetPostsForFeedOfUserRow
+	var items []SearchPostsForUserRow
 	for rows.Next() {
-		var i GetPostsForFeedOfUserRow
+		var i SearchPostsForUserRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.Title,
 			&i.Url,
-			&i.Description,
 			&i.PublishedAt,
-			&i.Feedid,
-			&i.Feedname,
+			&i.FeedName,
+			&i.Snippet,
 		); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// postGUID identifies an item within its feed, the normalized
+// URL is used for the feeds that don't give their items a guid
+func postGUID(guid, normalizedURL string) string {
+	if guid = strings.TrimSpace(guid); guid != "" {
+		return guid
+	}
+	return normalizedURL
+}
+
+// contentHash fingerprints what readers see of a post so edits can be told
+// apart from re-fetches, it must match the backfill in 013_posts_content_hash.sql
+func contentHash(title, description string) string {
+	sum := sha256.Sum256([]byte(title + "\n" + description))
+	return hex.EncodeToString(sum[:])
+}
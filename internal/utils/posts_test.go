@@ -0,0 +1,23 @@
+package utils
+
+import "testing"
+
+func TestPostGUID(t *testing.T) {
+	tests := []struct {
+		name string
+		guid string
+		url  string
+		want string
+	}{
+		{name: "guid", guid: " urn:1 ", url: "https://example.com/1", want: "urn:1"},
+		{name: "url fallback", guid: "  ", url: "https://example.com/1", want: "https://example.com/1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := postGUID(tt.guid, tt.url); got != tt.want {
+				t.Errorf("postGUID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
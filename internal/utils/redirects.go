@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/ricardosilva86/blogaggregator/internal/database"
+)
+
+// moveFeed points the feed to the URL it was permanently redirected to.
+// When another feed already uses that URL both are merged into it,
+// either way the old URL is kept as an alias so it still resolves
+func moveFeed(ctx context.Context, conn *sql.DB, feed database.Feed, newURL string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := database.New(tx)
+
+	target, err := q.GetFeedByURL(ctx, newURL)
+	switch {
+	case errors.Is(err, sql.ErrNoRows) || (err == nil && target.ID == feed.ID):
+		updateFeedURLParams := database.UpdateFeedURLParams{
+			ID:  feed.ID,
+			Url: newURL,
+		}
+		if err := q.UpdateFeedURL(ctx, updateFeedURLParams); err != nil {
+			return fmt.Errorf("error updating feed url: %w", err)
+		}
+		target = feed
+	case err != nil:
+		return fmt.Errorf("error looking up feed by url: %w", err)
+	default:
+		if err := mergeFeed(ctx, q, feed, target); err != nil {
+			return err
+		}
+	}
+
+	createFeedURLAliasParams := database.CreateFeedURLAliasParams{
+		Url:    feed.Url,
+		FeedID: target.ID,
+	}
+	if err := q.CreateFeedURLAlias(ctx, createFeedURLAliasParams); err != nil {
+		return fmt.Errorf("error creating feed url alias: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// mergeFeed moves the follows, posts and aliases of feed into target and deletes feed,
+// the posts target already has take over the stars and reads of their duplicates
+func mergeFeed(ctx context.Context, q *database.Queries, feed, target database.Feed) error {
+	moveFeedFollowsParams := database.MoveFeedFollowsParams{
+		ToFeedID:   target.ID,
+		FromFeedID: feed.ID,
+	}
+	if err := q.MoveFeedFollows(ctx, moveFeedFollowsParams); err != nil {
+		return fmt.Errorf("error moving feed follows: %w", err)
+	}
+
+	movePostsParams := database.MovePostsParams{
+		ToFeedID:   target.ID,
+		FromFeedID: feed.ID,
+	}
+	if err := q.MovePosts(ctx, movePostsParams); err != nil {
+		return fmt.Errorf("error moving posts: %w", err)
+	}
+
+	movePostStarsParams := database.MovePostStarsParams{
+		ToFeedID:   target.ID,
+		FromFeedID: feed.ID,
+	}
+	if err := q.MovePostStars(ctx, movePostStarsParams); err != nil {
+		return fmt.Errorf("error moving post stars: %w", err)
+	}
+
+	movePostReadsParams := database.MovePostReadsParams{
+		ToFeedID:   target.ID,
+		FromFeedID: feed.ID,
+	}
+	if err := q.MovePostReads(ctx, movePostReadsParams); err != nil {
+		return fmt.Errorf("error moving post reads: %w", err)
+	}
+
+	moveFeedURLAliasesParams := database.MoveFeedURLAliasesParams{
+		ToFeedID:   target.ID,
+		FromFeedID: feed.ID,
+	}
+	if err := q.MoveFeedURLAliases(ctx, moveFeedURLAliasesParams); err != nil {
+		return fmt.Errorf("error moving feed url aliases: %w", err)
+	}
+
+	if err := q.DeleteFeed(ctx, feed.ID); err != nil {
+		return fmt.Errorf("error deleting merged feed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"database/sql"
+	"github.com/ricardosilva86/blogaggregator/internal/config"
+	"time"
+)
+
+// a failing feed waits failureBackoffBase before being retried,
+// the wait doubles on every consecutive failure up to failureBackoffMax
+const (
+	failureBackoffBase = time.Minute
+	failureBackoffMax  = 24 * time.Hour
+)
+
+// cacheLifetimeMax caps how long the Cache-Control and Expires headers can
+// keep a feed from being fetched, a server caching it for a year doesn't
+// mean the feed isn't updated. What the feed itself declares isn't capped
+const cacheLifetimeMax = 24 * time.Hour
+
+// refreshInterval is how long to wait before fetching a healthy feed again
+func refreshInterval(hinted, cacheLifetime time.Duration) time.Duration {
+	return max(hinted, min(cacheLifetime, cacheLifetimeMax))
+}
+
+// retryAfter is how long to wait before fetching a failing feed again,
+// failing doesn't make it polled more often than it asked to be
+func retryAfter(failures int32, refreshIntervalSeconds sql.NullInt32) time.Duration {
+	wait := backoff(failures)
+	if refreshIntervalSeconds.Valid {
+		wait = max(wait, time.Duration(refreshIntervalSeconds.Int32)*time.Second)
+	}
+	return wait
+}
+
+// claimLease is how long a claimed feed is kept away from other aggregators,
+// it outlasts a fetch and its ingest so a feed is never fetched twice at once,
+// and a feed whose aggregator died is picked up again after it
+const claimLease = 15 * time.Minute
+
+// backoff is how long to wait before fetching a feed again
+// given how many times in a row it has failed before
+func backoff(failures int32) time.Duration {
+	wait := failureBackoffBase
+	for i := int32(0); i < failures; i++ {
+		wait *= 2
+		if wait >= failureBackoffMax {
+			return failureBackoffMax
+		}
+	}
+	return wait
+}
+
+// storedRefreshHints rebuilds the refresh hints saved on the feed,
+// they are needed when the server answers 304 without a body
+func storedRefreshHints(refreshIntervalSeconds sql.NullInt32, skipHours, skipDays []int32) config.RefreshHints {
+	var hints config.RefreshHints
+	if refreshIntervalSeconds.Valid {
+		hints.Interval = time.Duration(refreshIntervalSeconds.Int32) * time.Second
+	}
+	for _, hour := range skipHours {
+		hints.SkipHours = append(hints.SkipHours, int(hour))
+	}
+	for _, day := range skipDays {
+		hints.SkipDays = append(hints.SkipDays, time.Weekday(day))
+	}
+	return hints
+}
+
+// refreshHintsParams turns the refresh hints into the columns they are saved in
+func refreshHintsParams(hints config.RefreshHints) (refreshIntervalSeconds sql.NullInt32, skipHours, skipDays []int32) {
+	if hints.Interval > 0 {
+		refreshIntervalSeconds = sql.NullInt32{Int32: int32(hints.Interval / time.Second), Valid: true}
+	}
+	skipHours, skipDays = []int32{}, []int32{}
+	for _, hour := range hints.SkipHours {
+		skipHours = append(skipHours, int32(hour))
+	}
+	for _, day := range hints.SkipDays {
+		skipDays = append(skipDays, int32(day))
+	}
+	return refreshIntervalSeconds, skipHours, skipDays
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int32
+		want     time.Duration
+	}{
+		{failures: 0, want: time.Minute},
+		{failures: 1, want: 2 * time.Minute},
+		{failures: 5, want: 32 * time.Minute},
+		{failures: 11, want: failureBackoffMax},
+		{failures: 1000, want: failureBackoffMax},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.failures); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestRefreshInterval(t *testing.T) {
+	tests := []struct {
+		name          string
+		hinted        time.Duration
+		cacheLifetime time.Duration
+		want          time.Duration
+	}{
+		{name: "no hints", want: 0},
+		{name: "feed hint", hinted: time.Hour, cacheLifetime: time.Minute, want: time.Hour},
+		{name: "cache lifetime", hinted: time.Minute, cacheLifetime: 2 * time.Hour, want: 2 * time.Hour},
+		{name: "cache lifetime is capped", cacheLifetime: 365 * 24 * time.Hour, want: cacheLifetimeMax},
+		{name: "feed hint isn't capped", hinted: 7 * 24 * time.Hour, cacheLifetime: 365 * 24 * time.Hour, want: 7 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := refreshInterval(tt.hinted, tt.cacheLifetime); got != tt.want {
+				t.Errorf("refreshInterval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int32
+		interval sql.NullInt32
+		want     time.Duration
+	}{
+		{name: "no stored interval", failures: 0, want: time.Minute},
+		{name: "stored interval is longer", failures: 0, interval: sql.NullInt32{Int32: 3600, Valid: true}, want: time.Hour},
+		{name: "backoff is longer", failures: 7, interval: sql.NullInt32{Int32: 3600, Valid: true}, want: 128 * time.Minute},
+		{name: "stored interval isn't capped", failures: 0, interval: sql.NullInt32{Int32: 7 * 24 * 3600, Valid: true}, want: 7 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryAfter(tt.failures, tt.interval); got != tt.want {
+				t.Errorf("retryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,62 +2,281 @@ package utils
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
-	"github.com/google/uuid"
 	"github.com/ricardosilva86/blogaggregator/internal/config"
 	"github.com/ricardosilva86/blogaggregator/internal/database"
+	"strings"
+	"sync"
 	"time"
 )
 
-// ScrapeFeeds will scrape all feeds in the database
-func ScrapeFeeds(db *database.Queries) error {
-	feed, err := db.GetNextFeedToFetch(context.Background())
+// ErrDatabase marks the errors that come from the database rather than
+// from the feed being scraped, these are the ones the aggregator can't survive
+var ErrDatabase = errors.New("database error")
+
+// ErrShutdownTimeout is returned when the fetches in flight
+// didn't finish in time after being asked to stop
+var ErrShutdownTimeout = errors.New("in-flight fetches cancelled after the shutdown timeout")
+
+// ScrapeOptions controls how many feeds are claimed on every run
+// and how many of them are fetched at the same time
+type ScrapeOptions struct {
+	Client      *config.Client
+	Concurrency int
+	BatchSize   int
+	// MaxFailures disables a feed after that many consecutive failures, 0 never disables
+	MaxFailures int
+	// ShutdownTimeout is how long in-flight fetches are given
+	// to finish after the context is cancelled
+	ShutdownTimeout time.Duration
+}
+
+// ScrapeResult is the outcome of scraping a single feed
+type ScrapeResult struct {
+	Feed database.Feed
+	Err  error
+}
+
+// ScrapeFeeds claims the next batch of feeds to fetch and scrapes them
+// in parallel, an error in one feed doesn't stop the others.
+// Once ctx is cancelled no more feeds are started and the ones in flight
+// get opts.ShutdownTimeout to finish before being cancelled too,
+// ctx.Err() is returned when it is cancelled while claiming the feeds
+func ScrapeFeeds(ctx context.Context, conn *sql.DB, opts ScrapeOptions) ([]ScrapeResult, error) {
+	db := database.New(conn)
+	getNextFeedsToFetchParams := database.GetNextFeedsToFetchParams{
+		LeaseSeconds: int32(claimLease / time.Second),
+		BatchSize:    int32(opts.BatchSize),
+	}
+	feeds, err := db.GetNextFeedsToFetch(ctx, getNextFeedsToFetchParams)
 	if err != nil {
-		return fmt.Errorf("error fetching feeds: %w", err)
+		if ctx.Err() != nil {
+			// we are shutting down, the database is fine
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: error fetching feeds: %w", ErrDatabase, err)
 	}
 
-	newFeed, err := config.FetchFeed(context.Background(), feed.Url)
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+	stopGrace := context.AfterFunc(ctx, func() {
+		time.AfterFunc(opts.ShutdownTimeout, cancelWork)
+	})
+	defer stopGrace()
+
+	results := make([]ScrapeResult, len(feeds))
+	workers := make(chan struct{}, max(opts.Concurrency, 1))
+	var wg sync.WaitGroup
+	started := 0
+dispatch:
+	for i, feed := range feeds {
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+			break dispatch
+		}
+		started++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+			results[i] = ScrapeResult{Feed: feed, Err: scrapeFeed(workCtx, conn, db, feed, opts)}
+		}()
+	}
+	wg.Wait()
+
+	if workCtx.Err() != nil {
+		return results[:started], ErrShutdownTimeout
+	}
+	return results[:started], nil
+}
+
+// scrapeFeed fetches a single feed and stores its posts
+func scrapeFeed(ctx context.Context, conn *sql.DB, db *database.Queries, feed database.Feed, opts ScrapeOptions) error {
+	res, err := opts.Client.FetchFeedConditional(ctx, feed.Url, feed.Etag.String, feed.LastModified.String)
 	if err != nil {
-		return fmt.Errorf("error scraping feed: %w", err)
+		if ctx.Err() != nil {
+			// we are shutting down, it isn't the feed's fault
+			return err
+		}
+		return recordFailure(ctx, db, feed, opts, fmt.Errorf("error scraping feed: %w", err))
 	}
 
-	if _, err := db.MarkFeedFetched(context.Background(), feed.ID); err != nil {
-		return fmt.Errorf("error marking feed fetched: %w", err)
+	// a 304 may not repeat the validators, in that case we keep the ones we have
+	etag, lastModified := feed.Etag, feed.LastModified
+	if res.ETag != "" {
+		etag = sql.NullString{String: res.ETag, Valid: true}
+	}
+	if res.LastModified != "" {
+		lastModified = sql.NullString{String: res.LastModified, Valid: true}
 	}
 
-	for _, item := range newFeed.Channel.Item {
-		pubDate, err := parseDate(item.PubDate)
+	// never poll the feed more often than the feed itself or the server ask
+	hints := storedRefreshHints(feed.RefreshIntervalSeconds, feed.SkipHours, feed.SkipDays)
+	var siteURL sql.NullString
+	if res.Feed != nil {
+		hints = res.Feed.RefreshHints()
+		if link := strings.TrimSpace(res.Feed.Channel.Link); link != "" {
+			siteURL = sql.NullString{String: link, Valid: true}
+		}
+	}
+	refreshIntervalSeconds, skipHours, skipDays := refreshHintsParams(hints)
+
+	markFeedFetchedParams := database.MarkFeedFetchedParams{
+		ID:                     feed.ID,
+		Etag:                   etag,
+		LastModified:           lastModified,
+		LastStatusCode:         sql.NullInt32{Int32: int32(res.StatusCode), Valid: true},
+		NextFetchSeconds:       int32(refreshInterval(hints.Interval, res.CacheLifetime) / time.Second),
+		RefreshIntervalSeconds: refreshIntervalSeconds,
+		SkipHours:              skipHours,
+		SkipDays:               skipDays,
+		SiteUrl:                siteURL,
+	}
+
+	// the posts and the fetch are committed together, after a crash halfway
+	// the feed is fetched again once its claim expires and its posts stored then
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: error starting transaction: %w", ErrDatabase, err)
+	}
+	defer tx.Rollback()
+	qtx := db.WithTx(tx)
+
+	var stored []storedPost
+	if !res.NotModified {
+		stored, err = storePosts(ctx, qtx, feed, res.Feed.Channel.Item)
 		if err != nil {
-			fmt.Printf("Error type: %T\nError message: %v\n", err, err)
-			continue
+			tx.Rollback()
+			if ctx.Err() != nil {
+				return err
+			}
+			return recordFailure(ctx, db, feed, opts, fmt.Errorf("error storing posts: %w", err))
+		}
+	}
+	if _, err := qtx.MarkFeedFetched(ctx, markFeedFetchedParams); err != nil {
+		return fmt.Errorf("%w: error marking feed fetched: %w", ErrDatabase, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: error committing feed: %w", ErrDatabase, err)
+	}
+
+	if res.NotModified {
+		fmt.Printf("%s not modified since last fetch\n", feed.Name)
+	}
+	for _, post := range stored {
+		if post.Edited {
+			fmt.Printf("%s: %s (edited)\n", feed.Name, post.Title)
+		} else {
+			fmt.Printf("%s: %s\n", feed.Name, post.Title)
 		}
-		createPostParams := database.CreatePostParams{
-			ID:          uuid.New(),
-			FeedID:      feed.ID,
-			Title:       item.Title,
-			Url:         item.Link,
-			Description: item.Description,
-			PublishedAt: pubDate,
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
+	}
+
+	if res.PermanentURL != "" && res.PermanentURL != feed.Url {
+		if err := moveFeed(ctx, conn, feed, res.PermanentURL); err != nil {
+			return fmt.Errorf("error moving feed to %s: %w", res.PermanentURL, err)
 		}
-		_, err = db.CreatePost(context.Background(), createPostParams)
+		fmt.Printf("%s moved permanently to %s\n", feed.Name, res.PermanentURL)
+	}
+
+	return nil
+}
+
+// storedPost is a post that was inserted or rewritten by storePosts
+type storedPost struct {
+	Title  string
+	Edited bool
+}
+
+// storePosts upserts the items of the feed in a single statement,
+// items that can't be parsed are skipped rather than failing the feed
+func storePosts(ctx context.Context, db *database.Queries, feed database.Feed, items []config.RSSItem) ([]storedPost, error) {
+	upsertPostsParams := database.UpsertPostsParams{FeedID: feed.ID}
+	titles := make(map[string]string, len(items))
+	for _, item := range items {
+		pubDate, err := parseDate(item.PubDate)
 		if err != nil {
 			fmt.Printf("Error type: %T\nError message: %v\n", err, err)
+			continue
+		}
+		guid := postGUID(item.GUID, NormalizeURL(item.Link))
+		if _, ok := titles[guid]; ok {
+			// the same row can't be upserted twice in one statement
+			continue
 		}
-		fmt.Println(item.Title)
+		titles[guid] = item.Title
+
+		upsertPostsParams.Titles = append(upsertPostsParams.Titles, item.Title)
+		upsertPostsParams.Urls = append(upsertPostsParams.Urls, item.Link)
+		upsertPostsParams.Descriptions = append(upsertPostsParams.Descriptions, item.Description)
+		upsertPostsParams.PublishedAts = append(upsertPostsParams.PublishedAts, pubDate)
+		upsertPostsParams.Guids = append(upsertPostsParams.Guids, guid)
+		upsertPostsParams.ContentHashes = append(upsertPostsParams.ContentHashes, contentHash(item.Title, item.Description))
+	}
+	if len(upsertPostsParams.Guids) == 0 {
+		return nil, nil
 	}
 
-	return nil
+	adoptLegacyPostsParams := database.AdoptLegacyPostsParams{
+		Urls:   upsertPostsParams.Urls,
+		Guids:  upsertPostsParams.Guids,
+		FeedID: feed.ID,
+	}
+	if err := db.AdoptLegacyPosts(ctx, adoptLegacyPostsParams); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.UpsertPosts(ctx, upsertPostsParams)
+	if err != nil {
+		return nil, err
+	}
+	stored := make([]storedPost, 0, len(rows))
+	for _, row := range rows {
+		stored = append(stored, storedPost{Title: titles[row.Guid], Edited: !row.Inserted})
+	}
+	return stored, nil
+}
+
+// recordFailure stores the error on the feed so its health can be checked
+// later and backs off the next fetch, it returns the error it was given
+func recordFailure(ctx context.Context, db *database.Queries, feed database.Feed, opts ScrapeOptions, err error) error {
+	var statusCode sql.NullInt32
+	var statusErr *config.StatusError
+	if errors.As(err, &statusErr) {
+		statusCode = sql.NullInt32{Int32: int32(statusErr.StatusCode), Valid: true}
+	}
+	markFeedFailedParams := database.MarkFeedFailedParams{
+		ID:             feed.ID,
+		LastStatusCode: statusCode,
+		LastError:      sql.NullString{String: err.Error(), Valid: true},
+		RetrySeconds:   int32(retryAfter(feed.ConsecutiveFailures, feed.RefreshIntervalSeconds) / time.Second),
+	}
+	failed, dbErr := db.MarkFeedFailed(ctx, markFeedFailedParams)
+	if dbErr != nil {
+		return fmt.Errorf("%w: error recording feed failure: %w", ErrDatabase, dbErr)
+	}
+
+	if opts.MaxFailures > 0 && failed.ConsecutiveFailures >= int32(opts.MaxFailures) {
+		if dbErr := db.DisableFeed(ctx, feed.ID); dbErr != nil {
+			return fmt.Errorf("%w: error disabling feed: %w", ErrDatabase, dbErr)
+		}
+		return fmt.Errorf("feed disabled after %d consecutive failures: %w", failed.ConsecutiveFailures, err)
+	}
+	return err
 }
 
 func parseDate(dateStr string) (time.Time, error) {
 	layouts := []string{
 		time.RFC1123,
 		time.RFC1123Z,
+		time.RFC3339,
 		"Mon, 02 Jan 2006 15:04:05 -0700",
 		"2006-01-02T15:04:05Z",
 		"2006-01-02 15:04:05",
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02",
 	}
 
 	for _, layout := range layouts {
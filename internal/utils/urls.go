@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+)
+
+// NormalizeURL removes what changes in a post URL without it pointing to
+// another post: the fragment and the utm_* tracking parameters.
+// It is meant for comparing URLs, links are stored as the feed gives them
+func NormalizeURL(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	// the query is only rebuilt when something was removed from it,
+	// encoding it again would reorder and escape the parameters
+	if u.RawQuery != "" {
+		query := u.Query()
+		removed := false
+		for key := range query {
+			if strings.HasPrefix(strings.ToLower(key), "utm_") {
+				query.Del(key)
+				removed = true
+			}
+		}
+		if removed {
+			u.RawQuery = query.Encode()
+		}
+	}
+
+	return u.String()
+}
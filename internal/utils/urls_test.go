@@ -0,0 +1,30 @@
+package utils
+
+import "testing"
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{name: "unchanged", url: "https://example.com/post", want: "https://example.com/post"},
+		{name: "whitespace", url: "\n  https://example.com/post \n", want: "https://example.com/post"},
+		{name: "scheme and host case", url: "HTTPS://Example.COM/Post", want: "https://example.com/Post"},
+		{name: "fragment", url: "https://example.com/post#comments", want: "https://example.com/post"},
+		{name: "utm parameters", url: "https://example.com/post?utm_source=rss&UTM_Medium=feed", want: "https://example.com/post"},
+		{name: "utm parameters among others", url: "https://example.com/post?id=3&utm_source=rss", want: "https://example.com/post?id=3"},
+		{name: "query left alone without utm", url: "https://example.com/post?b=2&a=1&flag", want: "https://example.com/post?b=2&a=1&flag"},
+		{name: "escaped query left alone", url: "https://example.com/search?q=a+b%2Fc", want: "https://example.com/search?q=a+b%2Fc"},
+		{name: "relative url", url: "/post/1", want: "/post/1"},
+		{name: "invalid url", url: "http://exa mple.com/%zz", want: "http://exa mple.com/%zz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeURL(tt.url); got != tt.want {
+				t.Errorf("NormalizeURL(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
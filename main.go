@@ -1,22 +1,32 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"database/sql"
 	"errors"
+	"flag"
 	"fmt"
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/ricardosilva86/blogaggregator/internal/config"
 	"github.com/ricardosilva86/blogaggregator/internal/database"
 	"github.com/ricardosilva86/blogaggregator/internal/utils"
+	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
 type state struct {
-	cfg *config.Config
-	db  *database.Queries
+	cfg    *config.Config
+	conn   *sql.DB
+	db     *database.Queries
+	client *config.Client
 }
 
 type command struct {
@@ -25,16 +35,16 @@ type command struct {
 }
 
 type commands struct {
-	command map[string]func(*state, command) error
+	command map[string]func(context.Context, *state, command) error
 }
 
-func (c *commands) register(name string, f func(*state, command) error) {
+func (c *commands) register(name string, f func(context.Context, *state, command) error) {
 	c.command[name] = f
 }
 
-func (c *commands) run(s *state, cmd command) error {
+func (c *commands) run(ctx context.Context, s *state, cmd command) error {
 	if f, ok := c.command[cmd.name]; ok {
-		return f(s, cmd)
+		return f(ctx, s, cmd)
 	}
 	return fmt.Errorf("unknown command: %s", cmd.name)
 }
@@ -42,6 +52,7 @@ func (c *commands) run(s *state, cmd command) error {
 func main() {
 	if len(os.Args) < 2 {
 		fmt.Println("Usage: gator <command> <args> (optional)")
+		os.Exit(1)
 	}
 
 	c, err := config.Read()
@@ -58,12 +69,14 @@ func main() {
 
 	dbQueries := database.New(db)
 	s := &state{
-		cfg: &c,
-		db:  dbQueries,
+		cfg:    &c,
+		conn:   db,
+		db:     dbQueries,
+		client: config.NewClient(c.HTTP),
 	}
 
 	cmds := &commands{
-		command: map[string]func(*state, command) error{},
+		command: map[string]func(context.Context, *state, command) error{},
 	}
 
 	cmds.register("login", handlerLogin)
@@ -77,39 +90,61 @@ func main() {
 	cmds.register("following", middlewareLoggedIn(handleFollowing))
 	cmds.register("unfollow", middlewareLoggedIn(handleUnfollow))
 	cmds.register("browse", middlewareLoggedIn(handleBrowse))
+	cmds.register("read", middlewareLoggedIn(handleRead))
+	cmds.register("unread", middlewareLoggedIn(handleUnread))
+	cmds.register("mark-all-read", middlewareLoggedIn(handleMarkAllRead))
+	cmds.register("star", middlewareLoggedIn(handleStar))
+	cmds.register("unstar", middlewareLoggedIn(handleUnstar))
+	cmds.register("starred", middlewareLoggedIn(handleStarred))
+	cmds.register("search", middlewareLoggedIn(handleSearch))
+	cmds.register("categories", middlewareLoggedIn(handleCategories))
+	cmds.register("create-category", middlewareLoggedIn(handleCreateCategory))
+	cmds.register("rename-category", middlewareLoggedIn(handleRenameCategory))
+	cmds.register("delete-category", middlewareLoggedIn(handleDeleteCategory))
+	cmds.register("move-feed", middlewareLoggedIn(handleMoveFeed))
+	cmds.register("feed-health", middlewareLoggedIn(handlerFeedHealth))
+	cmds.register("enable-feed", middlewareLoggedIn(handlerEnableFeed))
+	cmds.register("import-opml", middlewareLoggedIn(handlerImportOPML))
+	cmds.register("export-opml", middlewareLoggedIn(handlerExportOPML))
+
+	// SIGINT and SIGTERM cancel the context so long running
+	// commands like agg can stop cleanly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	args := os.Args
-	err = cmds.run(s, command{
+	err = cmds.run(ctx, s, command{
 		name: args[1],
 		args: []string(args[2:]),
 	})
 	if err != nil {
 		fmt.Println(fmt.Errorf("error running command: %w", err))
+		os.Exit(1)
 	}
 
 }
 
-func middlewareLoggedIn(handler func(s *state, cmd command, user database.User) error) func(*state, command) error {
-	return func(s *state, c command) error {
+func middlewareLoggedIn(handler func(ctx context.Context, s *state, cmd command, user database.User) error) func(context.Context, *state, command) error {
+	return func(ctx context.Context, s *state, c command) error {
 		if s.cfg.CurrentUserName == "" {
 			return errors.New("not logged in")
 		}
-		user, err := s.db.GetUserByName(context.Background(), s.cfg.CurrentUserName)
+		user, err := s.db.GetUserByName(ctx, s.cfg.CurrentUserName)
 		if err != nil {
 			fmt.Println("You can't login to an account that doesn't exist!")
 			return err
 		}
 		// Call the original handler function
-		return handler(s, c, user)
+		return handler(ctx, s, c, user)
 	}
 }
 
-func handlerLogin(s *state, c command) error {
+func handlerLogin(ctx context.Context, s *state, c command) error {
 	if len(c.args) == 0 {
 		return fmt.Errorf("no username provided")
 	}
 
-	_, err := s.db.GetUserByName(context.Background(), c.args[0])
+	_, err := s.db.GetUserByName(ctx, c.args[0])
 	if err != nil {
 		fmt.Println("You can't login to an account that doesn't exist!")
 		os.Exit(1)
@@ -123,7 +158,7 @@ func handlerLogin(s *state, c command) error {
 	return nil
 }
 
-func handlerRegister(s *state, c command) error {
+func handlerRegister(ctx context.Context, s *state, c command) error {
 	if len(c.args) == 0 {
 		return fmt.Errorf("no username provided")
 	}
@@ -135,12 +170,12 @@ func handlerRegister(s *state, c command) error {
 		ID:        uuid.New(),
 	}
 
-	_, err := s.db.GetUserByName(context.Background(), name)
+	_, err := s.db.GetUserByName(ctx, name)
 	if err == nil {
 		fmt.Println("user already exists")
 		os.Exit(1)
 	}
-	user, err := s.db.CreateUser(context.Background(), userParams)
+	user, err := s.db.CreateUser(ctx, userParams)
 	if err != nil {
 		return fmt.Errorf("error creating user: %w", err)
 	}
@@ -155,8 +190,8 @@ func handlerRegister(s *state, c command) error {
 	return nil
 }
 
-func handlerReset(s *state, c command) error {
-	if err := s.db.ResetUsers(context.Background()); err != nil {
+func handlerReset(ctx context.Context, s *state, c command) error {
+	if err := s.db.ResetUsers(ctx); err != nil {
 		return fmt.Errorf("error resetting users: %w", err)
 	}
 
@@ -164,8 +199,8 @@ func handlerReset(s *state, c command) error {
 	return nil
 }
 
-func handlerListUsers(s *state, c command) error {
-	users, err := s.db.GetUsers(context.Background())
+func handlerListUsers(ctx context.Context, s *state, c command) error {
+	users, err := s.db.GetUsers(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to fetch all users: %w", err)
 	}
@@ -181,47 +216,151 @@ func handlerListUsers(s *state, c command) error {
 	return nil
 }
 
-func handlerAgg(s *state, c command) error {
-	if len(c.args) == 0 {
+func handlerAgg(ctx context.Context, s *state, c command) error {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	concurrency := fs.Int("concurrency", 5, "number of feeds fetched at the same time")
+	batchSize := fs.Int("batch", 10, "number of feeds fetched on every tick")
+	maxFailures := fs.Int("disable-after", 0, "disable a feed after this many consecutive failures, 0 never disables")
+	shutdownTimeout := fs.Duration("shutdown-timeout", 30*time.Second, "time given to in-flight fetches to finish when stopping")
+	args, err := parseFlags(fs, c.args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
 		return fmt.Errorf("no time provided")
 	}
-	t := c.args[0]
+	if *concurrency < 1 || *batchSize < 1 {
+		return fmt.Errorf("concurrency and batch must be greater than zero")
+	}
+	if *maxFailures < 0 {
+		return fmt.Errorf("disable-after can't be negative")
+	}
+	t := args[0]
 	timeBetweenRequests, err := time.ParseDuration(t)
 	if err != nil {
 		return fmt.Errorf("error parsing time: %w", err)
 	}
-	fmt.Printf("Collecting feeds every %s...\n", timeBetweenRequests)
+	opts := utils.ScrapeOptions{
+		Client:          s.client,
+		Concurrency:     *concurrency,
+		BatchSize:       *batchSize,
+		MaxFailures:     *maxFailures,
+		ShutdownTimeout: *shutdownTimeout,
+	}
+	fmt.Printf("Collecting %d feeds every %s with %d workers...\n", opts.BatchSize, timeBetweenRequests, opts.Concurrency)
 	ticker := time.NewTicker(timeBetweenRequests)
-	for ; ; <-ticker.C {
+	defer ticker.Stop()
+	for {
 		fmt.Println("Scraping feeds...")
-		err := utils.ScrapeFeeds(s.db)
+		results, err := utils.ScrapeFeeds(ctx, s.conn, opts)
+		if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+			// stopped before any feed was claimed
+			fmt.Println("Aggregator stopped")
+			return nil
+		}
 		if err != nil {
 			return fmt.Errorf("error scraping feeds: %w", err)
 		}
+		for _, result := range results {
+			if result.Err == nil {
+				continue
+			}
+			// a broken feed shouldn't take the aggregator down,
+			// only losing the database should
+			if errors.Is(result.Err, utils.ErrDatabase) {
+				return fmt.Errorf("error scraping feed %s: %w", result.Feed.Name, result.Err)
+			}
+			fmt.Printf("error scraping feed %s (%s): %v\n", result.Feed.Name, result.Feed.Url, result.Err)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+		if ctx.Err() != nil {
+			fmt.Println("Aggregator stopped")
+			return nil
+		}
 	}
+}
 
+// parseFlags parses the flags of a command, flags can be mixed
+// with the positional arguments, which are returned in order.
+// Everything after "--" is positional
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if consumed := len(args) - fs.NArg(); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, fs.Args()...), nil
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
 }
 
-func handlerAddFeed(s *state, c command, user database.User) error {
-	if len(c.args) == 0 {
-		fmt.Println("missing name and url")
-		os.Exit(1)
-	} else if len(c.args) == 1 {
+func handlerAddFeed(ctx context.Context, s *state, c command, user database.User) error {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	auto := fs.Bool("auto", false, "when the url is a web page, use the first feed found in it")
+	force := fs.Bool("force", false, "add the feed even if it can't be fetched or parsed")
+	args, err := parseFlags(fs, c.args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
 		fmt.Println("missing url")
 		os.Exit(1)
 	}
 
+	// the name is optional, the title of the feed is used when it's left out
+	name, feedURL := "", args[0]
+	if len(args) > 1 {
+		name, feedURL = args[0], args[1]
+	}
+
+	feed, err := s.client.FetchFeed(ctx, feedURL)
+	var page *config.HTMLPageError
+	if errors.As(err, &page) {
+		// a failed discovery goes through --force like a failed fetch,
+		// the page url is added as it is then
+		var discovered string
+		discovered, err = discoverFeed(ctx, s, page, *auto)
+		if err == nil {
+			feedURL = discovered
+			feed, err = s.client.FetchFeed(ctx, feedURL)
+		}
+	}
+	if err != nil {
+		if !*force {
+			return fmt.Errorf("%s is not a valid feed, use --force to add it anyway: %w", feedURL, err)
+		}
+		fmt.Printf("warning: %s is not a valid feed: %v\n", feedURL, err)
+	} else {
+		fmt.Printf("Found %q with %d items\n", feed.Channel.Title, len(feed.Channel.Item))
+		if name == "" {
+			name = strings.TrimSpace(feed.Channel.Title)
+		}
+	}
+	if name == "" {
+		return errors.New("missing name, the feed doesn't have a title to use instead")
+	}
+
 	feedParams := database.CreateFeedParams{
 		ID:        uuid.New(),
-		Name:      c.args[0],
-		Url:       c.args[1],
+		Name:      name,
+		Url:       feedURL,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		UserID:    user.ID,
 	}
-	f, err := s.db.CreateFeed(context.Background(), feedParams)
+	f, err := s.db.CreateFeed(ctx, feedParams)
 	if err != nil {
-		fmt.Printf("error creating feed: %w\n", err)
+		fmt.Printf("error creating feed: %v\n", err)
 		os.Exit(1)
 	}
 
@@ -234,9 +373,9 @@ func handlerAddFeed(s *state, c command, user database.User) error {
 		UserID:    user.ID,
 		FeedID:    f.ID,
 	}
-	_, err = s.db.CreateFeedFollow(context.Background(), feedFollowParams)
+	_, err = s.db.CreateFeedFollow(ctx, feedFollowParams)
 	if err != nil {
-		fmt.Printf("failed to follow newly created feed: %w\n", err)
+		fmt.Printf("failed to follow newly created feed: %v\n", err)
 		os.Exit(1)
 	}
 
@@ -245,10 +384,183 @@ func handlerAddFeed(s *state, c command, user database.User) error {
 	return nil
 }
 
-func handlerFeeds(s *state, c command, user database.User) error {
-	feeds, err := s.db.ListFeeds(context.Background(), user.ID)
+// discoverFeed finds the feeds of a web page and picks one of them,
+// the user is asked which one when there are many and auto is false
+func discoverFeed(ctx context.Context, s *state, page *config.HTMLPageError, auto bool) (string, error) {
+	fmt.Printf("%s is a web page, looking for its feeds...\n", page.URL)
+	candidates, err := s.client.DiscoverFeeds(ctx, page)
+	if err != nil {
+		return "", fmt.Errorf("error looking for feeds: %w", err)
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no feeds found at %s", page.URL)
+	}
+	if len(candidates) == 1 || auto {
+		fmt.Printf("Using %s\n", candidates[0])
+		return candidates[0].URL, nil
+	}
+
+	for i, candidate := range candidates {
+		fmt.Printf("%d. %s\n", i+1, candidate)
+	}
+	fmt.Printf("Pick a feed [1-%d]: ", len(candidates))
+	scanner := bufio.NewScanner(os.Stdin)
+	if !scanner.Scan() {
+		return "", errors.New("no feed picked")
+	}
+	choice, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+	if err != nil || choice < 1 || choice > len(candidates) {
+		return "", fmt.Errorf("invalid choice: %s", scanner.Text())
+	}
+	return candidates[choice-1].URL, nil
+}
+
+func handlerImportOPML(ctx context.Context, s *state, c command, user database.User) error {
+	if len(c.args) == 0 {
+		return errors.New("no opml file provided")
+	}
+	f, err := os.Open(c.args[0])
+	if err != nil {
+		return fmt.Errorf("error opening opml file: %w", err)
+	}
+	defer f.Close()
+
+	doc, err := config.ParseOPML(f)
+	if err != nil {
+		return fmt.Errorf("error parsing opml file: %w", err)
+	}
+
+	var created, followed, skipped, failed int
+	seen := map[string]bool{}
+	for _, opmlFeed := range doc.Feeds() {
+		if seen[opmlFeed.XMLURL] {
+			skipped++
+			continue
+		}
+		seen[opmlFeed.XMLURL] = true
+
+		feed, err := s.db.GetFeedByURL(ctx, opmlFeed.XMLURL)
+		if errors.Is(err, sql.ErrNoRows) {
+			name := opmlFeed.Title
+			if name == "" {
+				name = opmlFeed.XMLURL
+			}
+			feedParams := database.CreateFeedParams{
+				ID:        uuid.New(),
+				Name:      name,
+				Url:       opmlFeed.XMLURL,
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+				UserID:    user.ID,
+			}
+			feed, err = s.db.CreateFeed(ctx, feedParams)
+			if err == nil && opmlFeed.HTMLURL != "" {
+				setFeedSiteURLParams := database.SetFeedSiteURLParams{
+					ID:      feed.ID,
+					SiteUrl: sql.NullString{String: opmlFeed.HTMLURL, Valid: true},
+				}
+				err = s.db.SetFeedSiteURL(ctx, setFeedSiteURLParams)
+			}
+			if err == nil {
+				created++
+			}
+		}
+		if err != nil {
+			fmt.Printf("failed to import %s: %v\n", opmlFeed.XMLURL, err)
+			failed++
+			continue
+		}
+
+		feedFollowParams := database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			UpdatedAt: time.Now(),
+			CreatedAt: time.Now(),
+			UserID:    user.ID,
+			FeedID:    feed.ID,
+		}
+		_, err = s.db.CreateFeedFollow(ctx, feedFollowParams)
+		if err == nil && opmlFeed.Folder != "" {
+			err = setFollowCategory(ctx, s, user, feed.ID, opmlFeed.Folder)
+		}
+		switch {
+		case isUniqueViolation(err):
+			skipped++
+		case err != nil:
+			fmt.Printf("failed to follow %s: %v\n", opmlFeed.XMLURL, err)
+			failed++
+		default:
+			fmt.Printf("* %s\n", feed.Name)
+			followed++
+		}
+	}
+
+	fmt.Printf("Created %d feeds, followed %d, skipped %d, failed %d\n", created, followed, skipped, failed)
+	return nil
+}
+
+func handlerExportOPML(ctx context.Context, s *state, c command, user database.User) error {
+	getFeedFollowsForUserParams := database.GetFeedFollowsForUserParams{
+		UserID: user.ID,
+	}
+	follows, err := s.db.GetFeedFollowsForUser(ctx, getFeedFollowsForUserParams)
+	if err != nil {
+		return fmt.Errorf("failed to fetch follows for user %s: %w", user.Name, err)
+	}
+
+	doc := config.OPML{Version: "2.0"}
+	doc.Head.Title = fmt.Sprintf("%s's subscriptions in gator", user.Name)
+	doc.Head.DateCreated = time.Now().Format(time.RFC1123Z)
+	// categories become folders, follows come sorted by category
+	// so the feeds of a category are appended to the last folder
+	for _, follow := range follows {
+		outline := config.OPMLOutline{
+			Text:    follow.Feedname,
+			Title:   follow.Feedname,
+			Type:    "rss",
+			XMLURL:  follow.Feedurl,
+			HTMLURL: follow.Siteurl.String,
+		}
+		if !follow.Categoryname.Valid {
+			doc.Body.Outlines = append(doc.Body.Outlines, outline)
+			continue
+		}
+		last := len(doc.Body.Outlines) - 1
+		if last < 0 || doc.Body.Outlines[last].XMLURL != "" || doc.Body.Outlines[last].Text != follow.Categoryname.String {
+			doc.Body.Outlines = append(doc.Body.Outlines, config.OPMLOutline{Text: follow.Categoryname.String})
+			last++
+		}
+		doc.Body.Outlines[last].Outlines = append(doc.Body.Outlines[last].Outlines, outline)
+	}
+
+	// without a file the document goes to stdout
+	if len(c.args) == 0 {
+		return doc.Write(os.Stdout)
+	}
+	f, err := os.Create(c.args[0])
 	if err != nil {
-		fmt.Printf("failed to fetch feeds: %w\n", err)
+		return fmt.Errorf("error creating opml file: %w", err)
+	}
+	if err := doc.Write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing opml file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing opml file: %w", err)
+	}
+	fmt.Printf("Exported %d feeds to %s\n", len(follows), c.args[0])
+	return nil
+}
+
+// isUniqueViolation tells if the error comes from a unique constraint
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func handlerFeeds(ctx context.Context, s *state, c command, user database.User) error {
+	feeds, err := s.db.ListFeeds(ctx, user.ID)
+	if err != nil {
+		fmt.Printf("failed to fetch feeds: %v\n", err)
 		os.Exit(1)
 	}
 
@@ -256,15 +568,86 @@ func handlerFeeds(s *state, c command, user database.User) error {
 		fmt.Println(feed.Name)
 		fmt.Println(feed.Url)
 		fmt.Println(feed.Name_2)
+		fmt.Println(feedHealth(feed.LastFetchedAt, feed.DisabledAt, feed.LastStatusCode, feed.ConsecutiveFailures))
 	}
 	return nil
 }
 
-func handleFollow(s *state, c command, user database.User) error {
+func handlerFeedHealth(ctx context.Context, s *state, c command, user database.User) error {
+	feeds, err := s.db.GetFeedsHealthForUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch feeds health: %w", err)
+	}
+
+	for _, feed := range feeds {
+		fmt.Printf("* %s (%s)\n", feed.Name, feed.Url)
+		fmt.Printf("  status: %s\n", feedHealth(feed.LastFetchedAt, feed.DisabledAt, feed.LastStatusCode, feed.ConsecutiveFailures))
+		if feed.ConsecutiveFailures > 0 {
+			fmt.Printf("  consecutive failures: %d\n", feed.ConsecutiveFailures)
+		}
+		if feed.LastStatusCode.Valid {
+			fmt.Printf("  last status code: %d\n", feed.LastStatusCode.Int32)
+		}
+		if feed.LastSuccessAt.Valid {
+			fmt.Printf("  last success: %s\n", feed.LastSuccessAt.Time.Format(time.RFC1123))
+		} else {
+			fmt.Println("  last success: never")
+		}
+		if feed.LastError.Valid {
+			fmt.Printf("  last error: %s\n", feed.LastError.String)
+		}
+		if feed.RefreshIntervalSeconds.Valid {
+			fmt.Printf("  refresh interval: %s\n", time.Duration(feed.RefreshIntervalSeconds.Int32)*time.Second)
+		}
+		if feed.NextFetchAt.Valid && !feed.DisabledAt.Valid {
+			fmt.Printf("  next fetch: %s\n", feed.NextFetchAt.Time.Format(time.RFC1123))
+		}
+	}
+	return nil
+}
+
+func handlerEnableFeed(ctx context.Context, s *state, c command, user database.User) error {
+	if len(c.args) == 0 {
+		return fmt.Errorf("no feed url provided")
+	}
+	// the feed may have moved since it was disabled, its old URL still finds it
+	feed, err := s.db.GetFeedByURL(ctx, c.args[0])
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("feed %s not found", c.args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("error querying feed: %w", err)
+	}
+	feed, err = s.db.EnableFeed(ctx, feed.ID)
+	if err != nil {
+		return fmt.Errorf("error enabling feed: %w", err)
+	}
+
+	fmt.Printf("Feed %s enabled\n", feed.Name)
+	return nil
+}
+
+// feedHealth summarizes what happened the last times the feed was fetched
+func feedHealth(lastFetchedAt, disabledAt sql.NullTime, lastStatusCode sql.NullInt32, consecutiveFailures int32) string {
+	switch {
+	case disabledAt.Valid:
+		return "disabled"
+	case !lastFetchedAt.Valid:
+		return "pending"
+	case consecutiveFailures == 0:
+		return "ok"
+	case lastStatusCode.Int32 == http.StatusNotFound || lastStatusCode.Int32 == http.StatusGone:
+		return "dead"
+	default:
+		return "failing"
+	}
+}
+
+func handleFollow(ctx context.Context, s *state, c command, user database.User) error {
 	url := c.args[0]
-	feed, err := s.db.GetFeedByURL(context.Background(), url)
+	feed, err := s.db.GetFeedByURL(ctx, url)
 	if err != nil {
-		fmt.Printf("error querying feed: %w\n", err)
+		fmt.Printf("error querying feed: %v\n", err)
 		os.Exit(1)
 	}
 
@@ -276,9 +659,9 @@ func handleFollow(s *state, c command, user database.User) error {
 		FeedID:    feed.ID,
 	}
 
-	feedFollow, err := s.db.CreateFeedFollow(context.Background(), feedFollowParams)
+	feedFollow, err := s.db.CreateFeedFollow(ctx, feedFollowParams)
 	if err != nil {
-		fmt.Printf("error following feed with url: %w\n", err)
+		fmt.Printf("error following feed with url: %v\n", err)
 	}
 
 	fmt.Println(feedFollow)
@@ -286,50 +669,513 @@ func handleFollow(s *state, c command, user database.User) error {
 	return nil
 }
 
-func handleFollowing(s *state, c command, user database.User) error {
-	feeds, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
+func handleFollowing(ctx context.Context, s *state, c command, user database.User) error {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	category := fs.String("category", "", "only list the feeds in this category")
+	if _, err := parseFlags(fs, c.args); err != nil {
+		return err
+	}
+	categoryID, err := categoryFilter(ctx, s, user, *category)
 	if err != nil {
-		fmt.Printf("failed to fetch follows for user %s: %w\n", user.Name, err)
 		return err
 	}
-	fmt.Printf("%v\n", feeds)
+
+	getFeedFollowsForUserParams := database.GetFeedFollowsForUserParams{
+		UserID:     user.ID,
+		CategoryID: categoryID,
+	}
+	feeds, err := s.db.GetFeedFollowsForUser(ctx, getFeedFollowsForUserParams)
+	if err != nil {
+		fmt.Printf("failed to fetch follows for user %s: %v\n", user.Name, err)
+		return err
+	}
+	// feeds come sorted by category, a heading is printed when it changes
+	heading := ""
 	for _, feed := range feeds {
-		fmt.Println(feed.Feedname)
+		if feed.Categoryname.Valid && feed.Categoryname.String != heading {
+			heading = feed.Categoryname.String
+			fmt.Printf("%s:\n", heading)
+		}
+		indent := ""
+		if feed.Categoryname.Valid {
+			indent = "  "
+		}
+		fmt.Printf("%s%s (%d unread)\n", indent, feed.Feedname, feed.Unreadcount)
 	}
 	return nil
 }
 
-func handleUnfollow(s *state, c command, user database.User) error {
+func handleUnfollow(ctx context.Context, s *state, c command, user database.User) error {
 	feedFollow := database.DeleteFeedFollowParams{
 		UserID: user.ID,
 		Url:    c.args[0],
 	}
-	if err := s.db.DeleteFeedFollow(context.Background(), feedFollow); err != nil {
-		fmt.Printf("error unfollowing feed: %w\n", err)
+	if err := s.db.DeleteFeedFollow(ctx, feedFollow); err != nil {
+		fmt.Printf("error unfollowing feed: %v\n", err)
 		os.Exit(1)
 	}
 	return nil
 }
 
-func handleBrowse(s *state, c command, user database.User) error {
-	listFeeds, err := s.db.ListFeeds(context.Background(), user.ID)
+func handleBrowse(ctx context.Context, s *state, c command, user database.User) error {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	before := fs.String("before", "", "show the posts older than this cursor")
+	after := fs.String("after", "", "show the posts newer than this cursor")
+	all := fs.Bool("all", false, "show the posts already read too")
+	category := fs.String("category", "", "only show the posts of the feeds in this category")
+	args, err := parseFlags(fs, c.args)
 	if err != nil {
-		return fmt.Errorf("error fetching posts for feed: %w\n", err)
+		return err
+	}
+	if *before != "" && *after != "" {
+		return fmt.Errorf("before and after can't be used together")
 	}
 
-	for _, feed := range listFeeds {
-		postParams := database.GetPostsForFeedOfUserParams{
-			FeedID: feed.ID,
-			UserID: user.ID,
+	limit := 2
+	if len(args) > 0 {
+		limit, err = strconv.Atoi(args[0])
+		if err != nil || limit < 1 {
+			return fmt.Errorf("limit must be a positive number: %s", args[0])
 		}
-		posts, err := s.db.GetPostsForFeedOfUser(context.Background(), postParams)
+	}
+
+	categoryID, err := categoryFilter(ctx, s, user, *category)
+	if err != nil {
+		return err
+	}
+
+	getPostsForUserParams := database.GetPostsForUserParams{
+		UserID:      user.ID,
+		IncludeRead: *all,
+		CategoryID:  categoryID,
+		RowLimit:    int32(limit),
+	}
+	if *before != "" {
+		publishedAt, id, err := parsePostCursor(*before)
 		if err != nil {
-			return fmt.Errorf("error fetching list of posts for feed: %s. Error is: %w\n", feed.Name, err)
+			return err
 		}
-		for _, post := range posts {
-			fmt.Println(post.Title, post.Url, post.PublishedAt)
+		getPostsForUserParams.BeforePublishedAt = sql.NullTime{Time: publishedAt, Valid: true}
+		getPostsForUserParams.BeforeID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	if *after != "" {
+		publishedAt, id, err := parsePostCursor(*after)
+		if err != nil {
+			return err
 		}
+		getPostsForUserParams.AfterPublishedAt = sql.NullTime{Time: publishedAt, Valid: true}
+		getPostsForUserParams.AfterID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	posts, err := s.db.GetPostsForUser(ctx, getPostsForUserParams)
+	if err != nil {
+		return fmt.Errorf("error fetching posts: %w", err)
 	}
+	if len(posts) == 0 {
+		fmt.Println("No posts found")
+		return nil
+	}
+	// paging forward returns the oldest posts first, they are always shown newest first
+	if *after != "" {
+		slices.Reverse(posts)
+	}
+
+	for _, post := range posts {
+		fmt.Printf("* %s (%s)%s\n", post.Title, post.FeedName, readStatus(post.Read, post.Edited))
+		fmt.Printf("  id: %s\n", post.ID)
+		fmt.Printf("  %s\n", post.Url)
+		fmt.Printf("  published: %s\n", post.PublishedAt.Format(time.RFC1123))
+	}
+	newest, oldest := posts[0], posts[len(posts)-1]
+	fmt.Printf("\nnewer posts: browse --after %s\n", postCursor(newest.PublishedAt, newest.ID))
+	fmt.Printf("older posts: browse --before %s\n", postCursor(oldest.PublishedAt, oldest.ID))
+	return nil
+}
+
+// readStatus describes whether the user has already read the post
+func readStatus(read, edited bool) string {
+	switch {
+	case edited:
+		return " [edited since read]"
+	case read:
+		return " [read]"
+	default:
+		return ""
+	}
+}
+
+func handleRead(ctx context.Context, s *state, c command, user database.User) error {
+	postID, err := postIDArg(c)
+	if err != nil {
+		return err
+	}
+	markPostReadParams := database.MarkPostReadParams{
+		UserID: user.ID,
+		PostID: postID,
+	}
+	n, err := s.db.MarkPostRead(ctx, markPostReadParams)
+	if err != nil {
+		return fmt.Errorf("error marking post as read: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("post %s not found in the feeds you follow", postID)
+	}
+	fmt.Println("Post marked as read")
+	return nil
+}
 
+func handleUnread(ctx context.Context, s *state, c command, user database.User) error {
+	postID, err := postIDArg(c)
+	if err != nil {
+		return err
+	}
+	markPostUnreadParams := database.MarkPostUnreadParams{
+		UserID: user.ID,
+		PostID: postID,
+	}
+	n, err := s.db.MarkPostUnread(ctx, markPostUnreadParams)
+	if err != nil {
+		return fmt.Errorf("error marking post as unread: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("post %s wasn't read", postID)
+	}
+	fmt.Println("Post marked as unread")
+	return nil
+}
+
+// postIDArg parses the post id commands receive as their first argument
+func postIDArg(c command) (uuid.UUID, error) {
+	if len(c.args) == 0 {
+		return uuid.Nil, fmt.Errorf("no post id provided")
+	}
+	postID, err := uuid.Parse(c.args[0])
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid post id: %s", c.args[0])
+	}
+	return postID, nil
+}
+
+func handleMarkAllRead(ctx context.Context, s *state, c command, user database.User) error {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	category := fs.String("category", "", "only mark the posts of the feeds in this category")
+	args, err := parseFlags(fs, c.args)
+	if err != nil {
+		return err
+	}
+	categoryID, err := categoryFilter(ctx, s, user, *category)
+	if err != nil {
+		return err
+	}
+
+	markAllPostsReadParams := database.MarkAllPostsReadParams{
+		UserID:     user.ID,
+		CategoryID: categoryID,
+	}
+	if len(args) > 0 {
+		feed, err := s.db.GetFeedByURL(ctx, args[0])
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("feed %s not found", args[0])
+		}
+		if err != nil {
+			return fmt.Errorf("error querying feed: %w", err)
+		}
+		markAllPostsReadParams.FeedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	}
+	n, err := s.db.MarkAllPostsRead(ctx, markAllPostsReadParams)
+	if err != nil {
+		return fmt.Errorf("error marking posts as read: %w", err)
+	}
+	fmt.Printf("%d posts marked as read\n", n)
+	return nil
+}
+
+// postCursor encodes the position of a post in the posts ordered by publication
+func postCursor(publishedAt time.Time, id uuid.UUID) string {
+	return publishedAt.Format(time.RFC3339Nano) + "_" + id.String()
+}
+
+func parsePostCursor(cursor string) (time.Time, uuid.UUID, error) {
+	publishedAt, id, ok := strings.Cut(cursor, "_")
+	if !ok {
+		return time.Time{}, uuid.Nil, fmt.Errorf("invalid cursor: %s", cursor)
+	}
+	t, err := time.Parse(time.RFC3339Nano, publishedAt)
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("invalid cursor: %s", cursor)
+	}
+	u, err := uuid.Parse(id)
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("invalid cursor: %s", cursor)
+	}
+	return t, u, nil
+}
+
+func handleStar(ctx context.Context, s *state, c command, user database.User) error {
+	postID, err := postIDArg(c)
+	if err != nil {
+		return err
+	}
+	starPostParams := database.StarPostParams{
+		UserID: user.ID,
+		PostID: postID,
+	}
+	n, err := s.db.StarPost(ctx, starPostParams)
+	if err != nil {
+		return fmt.Errorf("error starring post: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("post %s not found in the feeds you follow", postID)
+	}
+	fmt.Println("Post starred")
+	return nil
+}
+
+func handleUnstar(ctx context.Context, s *state, c command, user database.User) error {
+	postID, err := postIDArg(c)
+	if err != nil {
+		return err
+	}
+	unstarPostParams := database.UnstarPostParams{
+		UserID: user.ID,
+		PostID: postID,
+	}
+	n, err := s.db.UnstarPost(ctx, unstarPostParams)
+	if err != nil {
+		return fmt.Errorf("error unstarring post: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("post %s isn't starred", postID)
+	}
+	fmt.Println("Post unstarred")
+	return nil
+}
+
+func handleStarred(ctx context.Context, s *state, c command, user database.User) error {
+	posts, err := s.db.GetStarredPostsForUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("error fetching starred posts: %w", err)
+	}
+	if len(posts) == 0 {
+		fmt.Println("No starred posts")
+		return nil
+	}
+
+	for _, post := range posts {
+		fmt.Printf("* %s (%s)\n", post.Title, post.FeedName)
+		fmt.Printf("  id: %s\n", post.ID)
+		fmt.Printf("  %s\n", post.Url)
+		fmt.Printf("  published: %s\n", post.PublishedAt.Format(time.RFC1123))
+		fmt.Printf("  starred: %s\n", post.StarredAt.Format(time.RFC1123))
+	}
+	return nil
+}
+
+func handleSearch(ctx context.Context, s *state, c command, user database.User) error {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	limit := fs.Int("limit", 10, "maximum number of posts to show")
+	// flags only go before the query, so terms like -mysql exclude words
+	if err := fs.Parse(c.args); err != nil {
+		return err
+	}
+	args := fs.Args()
+	if len(args) == 0 {
+		return fmt.Errorf("no search query provided")
+	}
+	if *limit < 1 {
+		return fmt.Errorf("limit must be greater than zero")
+	}
+
+	// the query can be given quoted or as several arguments
+	searchPostsForUserParams := database.SearchPostsForUserParams{
+		Query:    strings.Join(args, " "),
+		UserID:   user.ID,
+		RowLimit: int32(*limit),
+	}
+	posts, err := s.db.SearchPostsForUser(ctx, searchPostsForUserParams)
+	if err != nil {
+		return fmt.Errorf("error searching posts: %w", err)
+	}
+	if len(posts) == 0 {
+		fmt.Println("No posts found")
+		return nil
+	}
+
+	for _, post := range posts {
+		fmt.Printf("* %s (%s)\n", post.Title, post.FeedName)
+		fmt.Printf("  id: %s\n", post.ID)
+		fmt.Printf("  %s\n", post.Url)
+		fmt.Printf("  published: %s\n", post.PublishedAt.Format(time.RFC1123))
+		fmt.Printf("  %s\n", strings.Join(strings.Fields(post.Snippet), " "))
+	}
+	return nil
+}
+
+// categoryFilter resolves the category given to a --category flag,
+// no category means no filter
+func categoryFilter(ctx context.Context, s *state, user database.User, name string) (uuid.NullUUID, error) {
+	if name == "" {
+		return uuid.NullUUID{}, nil
+	}
+	getCategoryByNameParams := database.GetCategoryByNameParams{
+		UserID: user.ID,
+		Name:   name,
+	}
+	category, err := s.db.GetCategoryByName(ctx, getCategoryByNameParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.NullUUID{}, fmt.Errorf("category %s not found", name)
+	}
+	if err != nil {
+		return uuid.NullUUID{}, fmt.Errorf("error querying category: %w", err)
+	}
+	return uuid.NullUUID{UUID: category.ID, Valid: true}, nil
+}
+
+// setFollowCategory files a followed feed under the category, creating it when needed
+func setFollowCategory(ctx context.Context, s *state, user database.User, feedID uuid.UUID, name string) error {
+	getOrCreateCategoryParams := database.GetOrCreateCategoryParams{
+		UserID: user.ID,
+		Name:   name,
+	}
+	category, err := s.db.GetOrCreateCategory(ctx, getOrCreateCategoryParams)
+	if err != nil {
+		return fmt.Errorf("error creating category: %w", err)
+	}
+	setFeedFollowCategoryParams := database.SetFeedFollowCategoryParams{
+		CategoryID: uuid.NullUUID{UUID: category.ID, Valid: true},
+		UserID:     user.ID,
+		FeedID:     feedID,
+	}
+	if _, err := s.db.SetFeedFollowCategory(ctx, setFeedFollowCategoryParams); err != nil {
+		return fmt.Errorf("error setting feed category: %w", err)
+	}
+	return nil
+}
+
+func handleCategories(ctx context.Context, s *state, c command, user database.User) error {
+	categories, err := s.db.GetCategoriesForUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("error fetching categories: %w", err)
+	}
+	if len(categories) == 0 {
+		fmt.Println("No categories")
+		return nil
+	}
+
+	for _, category := range categories {
+		fmt.Printf("* %s (%d feeds)\n", category.Name, category.FeedCount)
+	}
+	return nil
+}
+
+func handleCreateCategory(ctx context.Context, s *state, c command, user database.User) error {
+	if len(c.args) == 0 {
+		return fmt.Errorf("no category name provided")
+	}
+	name := strings.TrimSpace(c.args[0])
+	if name == "" {
+		return fmt.Errorf("category name can't be empty")
+	}
+	createCategoryParams := database.CreateCategoryParams{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Name:      name,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	_, err := s.db.CreateCategory(ctx, createCategoryParams)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("category %s already exists", name)
+	}
+	if err != nil {
+		return fmt.Errorf("error creating category: %w", err)
+	}
+	fmt.Printf("Category %s created\n", name)
+	return nil
+}
+
+func handleRenameCategory(ctx context.Context, s *state, c command, user database.User) error {
+	if len(c.args) < 2 {
+		return fmt.Errorf("usage: %s <category> <new name>", c.name)
+	}
+	newName := strings.TrimSpace(c.args[1])
+	if newName == "" {
+		return fmt.Errorf("category name can't be empty")
+	}
+	renameCategoryParams := database.RenameCategoryParams{
+		NewName: newName,
+		UserID:  user.ID,
+		Name:    c.args[0],
+	}
+	n, err := s.db.RenameCategory(ctx, renameCategoryParams)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("category %s already exists", newName)
+	}
+	if err != nil {
+		return fmt.Errorf("error renaming category: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("category %s not found", c.args[0])
+	}
+	fmt.Printf("Category %s renamed to %s\n", c.args[0], newName)
+	return nil
+}
+
+func handleDeleteCategory(ctx context.Context, s *state, c command, user database.User) error {
+	if len(c.args) == 0 {
+		return fmt.Errorf("no category name provided")
+	}
+	deleteCategoryParams := database.DeleteCategoryParams{
+		UserID: user.ID,
+		Name:   c.args[0],
+	}
+	n, err := s.db.DeleteCategory(ctx, deleteCategoryParams)
+	if err != nil {
+		return fmt.Errorf("error deleting category: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("category %s not found", c.args[0])
+	}
+	fmt.Printf("Category %s deleted, its feeds are now uncategorized\n", c.args[0])
+	return nil
+}
+
+func handleMoveFeed(ctx context.Context, s *state, c command, user database.User) error {
+	if len(c.args) == 0 {
+		return fmt.Errorf("usage: %s <feed url> [category]", c.name)
+	}
+	feed, err := s.db.GetFeedByURL(ctx, c.args[0])
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("feed %s not found", c.args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("error querying feed: %w", err)
+	}
+
+	// without a category the feed is taken out of its category
+	var categoryID uuid.NullUUID
+	if len(c.args) > 1 {
+		categoryID, err = categoryFilter(ctx, s, user, c.args[1])
+		if err != nil {
+			return err
+		}
+	}
+	setFeedFollowCategoryParams := database.SetFeedFollowCategoryParams{
+		CategoryID: categoryID,
+		UserID:     user.ID,
+		FeedID:     feed.ID,
+	}
+	n, err := s.db.SetFeedFollowCategory(ctx, setFeedFollowCategoryParams)
+	if err != nil {
+		return fmt.Errorf("error moving feed: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("you don't follow %s", feed.Name)
+	}
+	if categoryID.Valid {
+		fmt.Printf("%s moved to %s\n", feed.Name, c.args[1])
+	} else {
+		fmt.Printf("%s is now uncategorized\n", feed.Name)
+	}
 	return nil
 }
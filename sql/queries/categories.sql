@@ -0,0 +1,32 @@
+-- name: CreateCategory :one
+insert into categories (id, user_id, name, created_at, updated_at)
+values ($1, $2, $3, $4, $5)
+returning *;
+
+-- name: GetOrCreateCategory :one
+insert into categories (user_id, name)
+values ($1, $2)
+on conflict (user_id, name) do update set name = excluded.name
+returning *;
+
+-- name: GetCategoryByName :one
+select * from categories
+where user_id = $1 and name = $2;
+
+-- name: GetCategoriesForUser :many
+select categories.id,
+       categories.name,
+       count(feed_follows.id) as feed_count
+from categories
+left join feed_follows on feed_follows.category_id = categories.id
+where categories.user_id = $1
+group by categories.id, categories.name
+order by categories.name;
+
+-- name: RenameCategory :execrows
+update categories set name = @new_name, updated_at = now()
+where user_id = @user_id and name = @name;
+
+-- name: DeleteCategory :execrows
+delete from categories
+where user_id = $1 and name = $2;
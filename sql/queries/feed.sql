@@ -10,15 +10,87 @@ on users.id = feeds.user_id
 where user_id = $1;
 
 -- name: GetFeedByURL :one
+-- resolves the old URLs of feeds that were permanently redirected
 select * from feeds
-where url = $1;
+where url = $1
+or id = (select feed_id from feed_url_aliases where feed_url_aliases.url = $1)
+order by url = $1 desc
+limit 1;
+
+-- name: UpdateFeedURL :exec
+update feeds set url = $2, updated_at = now()
+where id = $1;
+
+-- name: SetFeedSiteURL :exec
+update feeds set site_url = $2, updated_at = now()
+where id = $1;
+
+-- name: DeleteFeed :exec
+delete from feeds
+where id = $1;
 
 -- name: MarkFeedFetched :one
-update feeds set updated_at = now(), last_fetched_at = now()
+-- next_fetch_at is next_fetch_seconds from now on the database clock, moved forward
+-- an hour at a time until it is out of skip_hours and skip_days, which are in GMT,
+-- but never more than a week in case the feed asks to skip every hour of every day
+update feeds set updated_at = now(), last_fetched_at = now(), etag = @etag, last_modified = @last_modified,
+    last_status_code = @last_status_code, last_error = null, consecutive_failures = 0, last_success_at = now(),
+    refresh_interval_seconds = @refresh_interval_seconds, skip_hours = @skip_hours, skip_days = @skip_days,
+    next_fetch_at = case
+        when @next_fetch_seconds::integer = 0 and cardinality(@skip_hours::integer[]) = 0 and cardinality(@skip_days::integer[]) = 0 then null
+        else (
+            select candidate from (
+                select 0 as step, now() + make_interval(secs => @next_fetch_seconds::integer) as candidate
+                union all
+                select step, (date_trunc('hour', (now() + make_interval(secs => @next_fetch_seconds::integer)) at time zone 'UTC') at time zone 'UTC') + make_interval(hours => step)
+                from generate_series(1, 7 * 24) as step
+            ) as candidates
+            where (extract(hour from candidate at time zone 'UTC')::integer <> all(@skip_hours::integer[])
+                and extract(dow from candidate at time zone 'UTC')::integer <> all(@skip_days::integer[]))
+            or step = 7 * 24
+            order by step
+            limit 1
+        )
+    end,
+    site_url = coalesce(sqlc.narg('site_url'), site_url)
+where id = @id
+returning *;
+
+-- name: MarkFeedFailed :one
+-- the retry is counted from the database clock like the lease in GetNextFeedsToFetch
+update feeds set updated_at = now(), last_fetched_at = now(),
+    last_status_code = @last_status_code, last_error = @last_error, consecutive_failures = consecutive_failures + 1,
+    next_fetch_at = now() + make_interval(secs => @retry_seconds::integer)
+where id = @id
+returning *;
+
+-- name: DisableFeed :exec
+update feeds set updated_at = now(), disabled_at = now()
+where id = $1;
+
+-- name: EnableFeed :one
+update feeds set updated_at = now(), disabled_at = null, consecutive_failures = 0, next_fetch_at = null
 where id = $1
 returning *;
 
--- name: GetNextFeedToFetch :one
-select * from feeds
-order by feeds.last_fetched_at NULLS FIRST
-limit 1;
\ No newline at end of file
+-- name: GetFeedsHealthForUser :many
+select feeds.* from feeds
+join feed_follows on feed_follows.feed_id = feeds.id
+where feed_follows.user_id = $1
+order by feeds.consecutive_failures desc, feeds.name;
+
+-- name: GetNextFeedsToFetch :many
+-- claims the due feeds by leasing them, next_fetch_at is pushed lease_seconds
+-- forward so other aggregators don't pick them up while they are being fetched,
+-- fetching the feed then replaces the lease with its real next fetch.
+-- last_fetched_at is left to the fetch so a feed that never got stored stays first in line
+update feeds set next_fetch_at = now() + make_interval(secs => @lease_seconds::integer)
+where id in (
+    select id from feeds
+    where disabled_at is null
+    and (next_fetch_at is null or next_fetch_at <= now())
+    order by feeds.last_fetched_at NULLS FIRST
+    limit @batch_size
+    for update skip locked
+)
+returning *;
@@ -12,17 +12,45 @@ INNER JOIN feeds ON feeds.id = inserted_feed_follow.feed_id;
 
 
 -- name: GetFeedFollowsForUser :many
+-- feeds come grouped by category, the uncategorized ones first
 SELECT feed_follows.id,
        feeds.id as feedId,
        feeds.name as feedName,
+       feeds.url as feedUrl,
+       feeds.site_url as siteUrl,
        users.id as userId,
-       users.name as userName
+       users.name as userName,
+       categories.name as categoryName,
+       (select count(*) from posts
+        left join post_reads on post_reads.post_id = posts.id and post_reads.user_id = feed_follows.user_id
+        where posts.feed_id = feeds.id
+        and (post_reads.post_id is null or post_reads.content_hash <> posts.content_hash)) as unreadCount
 FROM public.feed_follows
 INNER JOIN users ON users.id = feed_follows.user_id
 INNER JOIN feeds ON feeds.id = feed_follows.feed_id
-WHERE feed_follows.user_id = $1;
+LEFT JOIN categories ON categories.id = feed_follows.category_id
+WHERE feed_follows.user_id = @user_id
+AND (sqlc.narg(category_id)::uuid IS NULL OR feed_follows.category_id = sqlc.narg(category_id)::uuid)
+ORDER BY categories.name NULLS FIRST, feeds.name;
 
 -- name: DeleteFeedFollow :exec
 delete from feed_follows
 where feed_follows.user_id = $1
-and (select id from feeds where url = $2) = feed_id;
\ No newline at end of file
+and feed_id = (
+    select id from feeds where url = $2
+    union
+    select feed_url_aliases.feed_id from feed_url_aliases where feed_url_aliases.url = $2
+    limit 1
+);
+
+-- name: MoveFeedFollows :exec
+insert into feed_follows (id, user_id, feed_id, created_at, updated_at, category_id)
+select gen_random_uuid(), user_id, @to_feed_id::uuid, created_at, now(), category_id
+from feed_follows
+where feed_follows.feed_id = @from_feed_id
+on conflict (feed_id, user_id) do nothing;
+
+-- name: SetFeedFollowCategory :execrows
+-- a null category leaves the feed uncategorized
+update feed_follows set category_id = sqlc.narg(category_id), updated_at = now()
+where user_id = @user_id and feed_id = @feed_id;
@@ -0,0 +1,8 @@
+-- name: CreateFeedURLAlias :exec
+insert into feed_url_aliases (url, feed_id)
+values ($1, $2)
+on conflict (url) do update set feed_id = excluded.feed_id;
+
+-- name: MoveFeedURLAliases :exec
+update feed_url_aliases set feed_id = @to_feed_id
+where feed_id = @from_feed_id;
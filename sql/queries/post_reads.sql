@@ -0,0 +1,37 @@
+-- name: MarkPostRead :execrows
+-- only the posts of the feeds the user follows can be marked as read
+insert into post_reads (user_id, post_id, content_hash)
+select feed_follows.user_id, posts.id, posts.content_hash
+from posts
+inner join feed_follows on feed_follows.feed_id = posts.feed_id
+where feed_follows.user_id = @user_id and posts.id = @post_id
+on conflict (user_id, post_id) do update
+set content_hash = excluded.content_hash, read_at = now();
+
+-- name: MarkPostUnread :execrows
+delete from post_reads
+where user_id = $1 and post_id = $2;
+
+-- name: MarkAllPostsRead :execrows
+-- marks the unread posts of every feed the user follows, or only those of feed_id or category_id
+insert into post_reads (user_id, post_id, content_hash)
+select feed_follows.user_id, posts.id, posts.content_hash
+from posts
+inner join feed_follows on feed_follows.feed_id = posts.feed_id
+where feed_follows.user_id = @user_id
+and (sqlc.narg(feed_id)::uuid is null or posts.feed_id = sqlc.narg(feed_id)::uuid)
+and (sqlc.narg(category_id)::uuid is null or feed_follows.category_id = sqlc.narg(category_id)::uuid)
+on conflict (user_id, post_id) do update
+set content_hash = excluded.content_hash, read_at = now()
+where post_reads.content_hash <> excluded.content_hash;
+
+-- name: MovePostReads :exec
+-- moves the reads of the posts left behind by MovePosts to their copies in the destination feed,
+-- the read keeps the content it was made on so a copy with a different content is unread
+insert into post_reads (user_id, post_id, content_hash, read_at)
+select post_reads.user_id, target.id, post_reads.content_hash, post_reads.read_at
+from post_reads
+inner join posts on posts.id = post_reads.post_id
+inner join posts target on target.feed_id = @to_feed_id and target.guid = posts.guid
+where posts.feed_id = @from_feed_id
+on conflict (user_id, post_id) do nothing;
@@ -0,0 +1,38 @@
+-- name: StarPost :execrows
+-- only the posts of the feeds the user follows can be starred,
+-- starring a post twice keeps the first star
+insert into post_stars (user_id, post_id)
+select feed_follows.user_id, posts.id
+from posts
+inner join feed_follows on feed_follows.feed_id = posts.feed_id
+where feed_follows.user_id = @user_id and posts.id = @post_id
+on conflict (user_id, post_id) do update
+set created_at = post_stars.created_at;
+
+-- name: UnstarPost :execrows
+delete from post_stars
+where user_id = $1 and post_id = $2;
+
+-- name: GetStarredPostsForUser :many
+-- stars don't depend on follows, the posts of unfollowed feeds are listed too
+SELECT posts.id,
+       posts.title,
+       posts.url,
+       posts.published_at,
+       feeds.name AS feed_name,
+       post_stars.created_at AS starred_at
+FROM post_stars
+INNER JOIN posts ON posts.id = post_stars.post_id
+INNER JOIN feeds ON feeds.id = posts.feed_id
+WHERE post_stars.user_id = $1
+ORDER BY post_stars.created_at DESC;
+
+-- name: MovePostStars :exec
+-- moves the stars of the posts left behind by MovePosts to their copies in the destination feed
+insert into post_stars (user_id, post_id, created_at)
+select post_stars.user_id, target.id, post_stars.created_at
+from post_stars
+inner join posts on posts.id = post_stars.post_id
+inner join posts target on target.feed_id = @to_feed_id and target.guid = posts.guid
+where posts.feed_id = @from_feed_id
+on conflict (user_id, post_id) do nothing;
@@ -1,17 +1,93 @@
--- name: CreatePost :one
-INSERT INTO posts(id, feed_id, title, url, description, published_at, created_at, updated_at)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING *;
+-- name: AdoptLegacyPosts :exec
+-- posts stored before guids existed got their url as guid, when one of them
+-- is in the batch under its real guid it takes that guid instead of being duplicated
+update posts set guid = batch.guid
+from unnest(@urls::text[], @guids::text[]) as batch(url, guid)
+where posts.feed_id = @feed_id::uuid
+and posts.url = batch.url
+and posts.guid = posts.url
+and posts.guid <> batch.guid
+and not exists (
+    select 1 from posts existing
+    where existing.feed_id = posts.feed_id and existing.guid = batch.guid
+);
 
--- name: GetPostsForFeedOfUser :many
+-- name: UpsertPosts :many
+-- stores all the items of a feed in one statement, the batch must not repeat a guid.
+-- posts already stored are only rewritten when their content changed
+-- and only the inserted or rewritten posts are returned
+INSERT INTO posts(feed_id, title, url, description, published_at, guid, content_hash)
+SELECT @feed_id::uuid,
+       unnest(@titles::text[]),
+       unnest(@urls::text[]),
+       unnest(@descriptions::text[]),
+       unnest(@published_ats::timestamp[]),
+       unnest(@guids::text[]),
+       unnest(@content_hashes::text[])
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET title = excluded.title,
+    url = excluded.url,
+    description = excluded.description,
+    published_at = excluded.published_at,
+    content_hash = excluded.content_hash,
+    updated_at = now()
+WHERE posts.content_hash <> excluded.content_hash
+RETURNING guid, (xmax = 0) AS inserted;
+
+-- name: MovePosts :exec
+-- posts already in the destination feed stay behind and go away with the old feed
+update posts set feed_id = @to_feed_id, updated_at = now()
+where posts.feed_id = @from_feed_id
+and not exists (
+    select 1 from posts existing
+    where existing.feed_id = @to_feed_id and existing.guid = posts.guid
+);
+
+-- name: GetPostsForUser :many
+-- pages through the posts of the feeds the user follows with a (published_at, id) cursor,
+-- posts come newest first, or oldest first when paging forward with the after cursor.
+-- posts edited since the user read them count as unread
 SELECT posts.id,
        posts.title,
        posts.url,
        posts.description,
        posts.published_at,
-       feeds.id as feedId,
-       feeds.name as feedName
-FROM public.posts
+       feeds.id AS feed_id,
+       feeds.name AS feed_name,
+       (post_reads.post_id IS NOT NULL) AS read,
+       coalesce(post_reads.content_hash <> posts.content_hash, false) AS edited
+FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+INNER JOIN feeds ON feeds.id = posts.feed_id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = @user_id
+AND (@include_read::boolean OR post_reads.post_id IS NULL OR post_reads.content_hash <> posts.content_hash)
+AND (sqlc.narg(category_id)::uuid IS NULL OR feed_follows.category_id = sqlc.narg(category_id)::uuid)
+AND (sqlc.narg(before_published_at)::timestamp IS NULL
+     OR (posts.published_at, posts.id) < (sqlc.narg(before_published_at)::timestamp, sqlc.narg(before_id)::uuid))
+AND (sqlc.narg(after_published_at)::timestamp IS NULL
+     OR (posts.published_at, posts.id) > (sqlc.narg(after_published_at)::timestamp, sqlc.narg(after_id)::uuid))
+ORDER BY
+    CASE WHEN sqlc.narg(after_published_at)::timestamp IS NULL THEN posts.published_at END DESC,
+    CASE WHEN sqlc.narg(after_published_at)::timestamp IS NULL THEN posts.id END DESC,
+    posts.published_at ASC,
+    posts.id ASC
+LIMIT @row_limit;
+
+-- name: SearchPostsForUser :many
+-- full text search over the posts of the feeds the user follows, best matches first
+SELECT posts.id,
+       posts.title,
+       posts.url,
+       posts.published_at,
+       feeds.name AS feed_name,
+       ts_headline('english', posts.title || ' ' || posts.description, websearch_to_tsquery('english', @query::text),
+                   'StartSel=**, StopSel=**, MaxWords=25, MinWords=10, MaxFragments=2') AS snippet
+FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
 INNER JOIN feeds ON feeds.id = posts.feed_id
-WHERE (select feed_follows.feed_id from feed_follows where feed_follows.feed_id = posts.feed_id) = $1
-AND (select feed_follows.user_id from feed_follows where feed_follows.user_id = $2) = $2;
\ No newline at end of file
+WHERE feed_follows.user_id = @user_id
+AND posts.search_vector @@ websearch_to_tsquery('english', @query::text)
+ORDER BY ts_rank(posts.search_vector, websearch_to_tsquery('english', @query::text)) DESC,
+         posts.published_at DESC
+LIMIT @row_limit;
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE feeds
+    ADD COLUMN last_status_code integer default null,
+    ADD COLUMN last_error text default null,
+    ADD COLUMN consecutive_failures integer not null default 0,
+    ADD COLUMN last_success_at timestamp default null;
+
+-- +goose Down
+ALTER TABLE feeds
+    DROP COLUMN last_status_code,
+    DROP COLUMN last_error,
+    DROP COLUMN consecutive_failures,
+    DROP COLUMN last_success_at;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds
+    ADD COLUMN next_fetch_at timestamp default null,
+    ADD COLUMN disabled_at timestamp default null;
+
+-- +goose Down
+ALTER TABLE feeds
+    DROP COLUMN next_fetch_at,
+    DROP COLUMN disabled_at;
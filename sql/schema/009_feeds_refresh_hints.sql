@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE feeds
+    ADD COLUMN refresh_interval_seconds integer default null,
+    ADD COLUMN skip_hours integer[] not null default '{}',
+    ADD COLUMN skip_days integer[] not null default '{}';
+
+-- +goose Down
+ALTER TABLE feeds
+    DROP COLUMN refresh_interval_seconds,
+    DROP COLUMN skip_hours,
+    DROP COLUMN skip_days;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE feed_url_aliases (
+    url text PRIMARY KEY,
+    feed_id uuid NOT NULL,
+    created_at timestamp NOT NULL default now(),
+    constraint fk_feeds_feed_url_aliases
+        foreign key (feed_id)
+        references feeds(id)
+        on delete cascade
+);
+
+-- +goose Down
+drop table feed_url_aliases;
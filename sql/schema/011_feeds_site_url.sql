@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE feeds
+    ADD COLUMN site_url text default null;
+
+-- +goose Down
+ALTER TABLE feeds
+    DROP COLUMN site_url;
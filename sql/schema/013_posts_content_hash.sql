@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE posts
+    ADD COLUMN content_hash text NOT NULL DEFAULT '';
+UPDATE posts SET content_hash = encode(sha256(convert_to(title || E'\n' || description, 'UTF8')), 'hex');
+
+-- +goose Down
+ALTER TABLE posts
+    DROP COLUMN content_hash;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE post_reads (
+    user_id uuid NOT NULL,
+    post_id uuid NOT NULL,
+    -- the content of the post when it was read, a post edited since then is unread again
+    content_hash text NOT NULL,
+    read_at timestamp NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, post_id),
+    constraint fk_users_post_reads
+        foreign key (user_id)
+        references users(id)
+        on delete cascade,
+    constraint fk_posts_post_reads
+        foreign key (post_id)
+        references posts(id)
+        on delete cascade
+);
+
+-- +goose Down
+drop table post_reads;
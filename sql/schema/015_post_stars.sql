@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE post_stars (
+    user_id uuid NOT NULL,
+    post_id uuid NOT NULL,
+    created_at timestamp NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, post_id),
+    constraint fk_users_post_stars
+        foreign key (user_id)
+        references users(id)
+        on delete cascade,
+    constraint fk_posts_post_stars
+        foreign key (post_id)
+        references posts(id)
+        on delete cascade
+);
+
+-- +goose Down
+drop table post_stars;
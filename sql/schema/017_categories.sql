@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE categories (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    name text NOT NULL,
+    created_at timestamp NOT NULL DEFAULT now(),
+    updated_at timestamp NOT NULL DEFAULT now(),
+    UNIQUE (user_id, name),
+    constraint fk_users_categories
+        foreign key (user_id)
+        references users(id)
+        on delete cascade
+);
+
+-- deleting a category leaves its feeds uncategorized
+ALTER TABLE feed_follows
+    ADD COLUMN category_id uuid,
+    ADD CONSTRAINT fk_categories_feed_follows
+        FOREIGN KEY (category_id)
+        REFERENCES categories(id)
+        ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE feed_follows
+    DROP COLUMN category_id;
+drop table categories;